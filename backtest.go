@@ -29,8 +29,9 @@ package history
 
 import (
 	"errors"
-	"fmt"
 	"log"
+	"math"
+	"sort"
 	"time"
 )
 
@@ -43,6 +44,28 @@ type Portfolio struct {
 	Closed     Positions
 	Balance    float64
 	Unreleased float64
+	Stats      PortfolioStats
+	initial    float64
+}
+
+// PortfolioStats of closed positions. break-even trades count in TotalTrades
+// but not as wins or losses, and are left out of WinRate
+type PortfolioStats struct {
+	TotalTrades   int
+	WinningTrades int
+	LosingTrades  int
+	WinRate       float64 // percent of wins out of wins and losses
+	GrossProfit   float64 // sum of winning trades
+	GrossLoss     float64 // sum of losing trades, positive
+	ProfitFactor  float64 // GrossProfit / GrossLoss, 0 without losses
+	Expectancy    float64 // average profit per trade
+	AvgWin        float64
+	AvgLoss       float64 // positive
+	// longest streaks, a break-even trade ends a streak
+	MaxConsecutiveWins   int
+	MaxConsecutiveLosses int
+	// current streak, positive wins and negative losses
+	streak int
 }
 
 type Position struct {
@@ -53,13 +76,200 @@ type Position struct {
 	openPrice  float64
 	closePrice float64
 	size       float64
+	stop       float64
+	target     float64
 	profit     float64
 	perc       float64
-	isClosed   bool
+	// fee paid to open and close, taken from profit
+	fee      float64
+	isClosed bool
 }
 
 type Positions []Position
 
+// PortfolioStrategy is a Strategy that keeps portfolio state between runs
+type PortfolioStrategy interface {
+	Strategy
+	Reset()
+}
+
+// NewPortfolio returns a Portfolio starting with balance
+func NewPortfolio(balance float64) *Portfolio {
+	p := &Portfolio{initial: balance}
+	p.Reset()
+	return p
+}
+
+// Reset restores initial balance and clears all positions
+func (p *Portfolio) Reset() {
+	if p.initial == 0 {
+		p.initial = initial
+	}
+	p.Open = nil
+	p.Closed = nil
+	p.Balance = p.initial
+	p.Unreleased = 0
+	p.Stats = PortfolioStats{}
+}
+
+// updateStats with profit of a closed position
+func (p *Portfolio) updateStats(profit float64) {
+	s := &p.Stats
+	s.TotalTrades++
+	switch {
+	case profit > 0:
+		s.WinningTrades++
+		s.GrossProfit += profit
+		s.AvgWin = s.GrossProfit / float64(s.WinningTrades)
+		if s.streak < 0 {
+			s.streak = 0
+		}
+		s.streak++
+		if s.streak > s.MaxConsecutiveWins {
+			s.MaxConsecutiveWins = s.streak
+		}
+	case profit < 0:
+		s.LosingTrades++
+		s.GrossLoss -= profit
+		s.AvgLoss = s.GrossLoss / float64(s.LosingTrades)
+		if s.streak > 0 {
+			s.streak = 0
+		}
+		s.streak--
+		if -s.streak > s.MaxConsecutiveLosses {
+			s.MaxConsecutiveLosses = -s.streak
+		}
+	default:
+		s.streak = 0
+	}
+	if n := s.WinningTrades + s.LosingTrades; n > 0 {
+		s.WinRate = 100 * float64(s.WinningTrades) / float64(n)
+	}
+	if s.GrossLoss > 0 {
+		s.ProfitFactor = s.GrossProfit / s.GrossLoss
+	}
+	s.Expectancy = (s.GrossProfit - s.GrossLoss) / float64(s.TotalTrades)
+}
+
+// Initial returns the starting balance
+func (p *Portfolio) Initial() float64 {
+	if p.initial == 0 {
+		return initial
+	}
+	return p.initial
+}
+
+// Value returns balance with the cost of open positions added back,
+// since Add deducts it from balance
+func (p *Portfolio) Value() float64 {
+	value := p.Balance
+	for _, pos := range p.Open {
+		value += pos.openPrice * pos.size
+	}
+	return value
+}
+
+// Gain returns Value change in percent of the starting balance, open positions count at cost
+func (p *Portfolio) Gain() float64 {
+	return 100 * (p.Value()/p.Initial() - 1)
+}
+
+// EquityPoint is the balance after a closed position
+type EquityPoint struct {
+	Time    time.Time
+	Balance float64
+}
+
+// Equity returns the balance curve of closed positions in closing order,
+// starting with the initial balance at the first open time
+func (p *Portfolio) Equity() []EquityPoint {
+	if len(p.Closed) == 0 {
+		return nil
+	}
+
+	closed := make(Positions, len(p.Closed))
+	copy(closed, p.Closed)
+	sort.SliceStable(closed, func(i, j int) bool {
+		return closed[i].closeTime.Before(closed[j].closeTime)
+	})
+
+	first := closed[0].openTime
+	for _, pos := range closed {
+		if pos.openTime.Before(first) {
+			first = pos.openTime
+		}
+	}
+
+	balance := p.Initial()
+	equity := []EquityPoint{{first, balance}}
+	for _, pos := range closed {
+		balance += pos.profit
+		equity = append(equity, EquityPoint{pos.closeTime, balance})
+	}
+	return equity
+}
+
+// MaxDrawdown returns the largest fall of Equity in percent below its running peak
+func (p *Portfolio) MaxDrawdown() float64 {
+	var peak, dd float64
+	for _, e := range p.Equity() {
+		if e.Balance > peak {
+			peak = e.Balance
+		}
+		if peak > 0 && 100*(1-e.Balance/peak) > dd {
+			dd = 100 * (1 - e.Balance/peak)
+		}
+	}
+	return dd
+}
+
+// Sharpe returns mean over standard deviation of closed position returns,
+// per trade and not annualized. 0 with less then 2 trades
+func (p *Portfolio) Sharpe() float64 {
+	var returns []float64
+	for _, pos := range p.Closed {
+		if v := pos.openPrice * pos.size; v != 0 {
+			returns = append(returns, pos.profit/v)
+		}
+	}
+	if len(returns) < 2 {
+		return 0
+	}
+
+	var mean float64
+	for _, r := range returns {
+		mean += r
+	}
+	mean /= float64(len(returns))
+
+	var variance float64
+	for _, r := range returns {
+		variance += (r - mean) * (r - mean)
+	}
+	std := math.Sqrt(variance / float64(len(returns)-1))
+	if std == 0 {
+		return 0
+	}
+	return mean / std
+}
+
+// RiskSize returns position size that loses riskPct of balance if stop is hit
+func (p *Portfolio) RiskSize(entry, stop, riskPct float64) float64 {
+	risk := math.Abs(entry - stop)
+	if risk == 0 {
+		return 0
+	}
+	return p.Balance * riskPct / 100 / risk
+}
+
+// FixedFractionalSize returns position size worth pct of balance at price
+func (p *Portfolio) FixedFractionalSize(price, pct float64) float64 {
+	if price == 0 {
+		return 0
+	}
+	return p.Balance * pct / 100 / price
+}
+
 // MakePosition converts Event to Position
 func MakePosition(ev Event, size float64) Position {
 	var new Position
@@ -68,9 +278,31 @@ func MakePosition(ev Event, size float64) Position {
 	new.openTime = ev.Time
 	new.openPrice = ev.Price
 	new.size = size // ?
+	new.stop = ev.Stop
+	new.target = ev.Target
 	return new
 }
 
+// exit returns the stop or target price hit by bar, stop first if bar hits both
+func (p Position) exit(bar Bar) (price float64, name string, ok bool) {
+	if p.isBuy {
+		if p.stop != 0 && bar.Low <= p.stop {
+			return p.stop, "Stop", true
+		}
+		if p.target != 0 && bar.High >= p.target {
+			return p.target, "Target", true
+		}
+	} else {
+		if p.stop != 0 && bar.High >= p.stop {
+			return p.stop, "Stop", true
+		}
+		if p.target != 0 && bar.Low <= p.target {
+			return p.target, "Target", true
+		}
+	}
+	return 0, "", false
+}
+
 // Add a position to portfolio
 func (p *Portfolio) Add(new Position) (bool, error) {
 	if new.symbol == "" {
@@ -87,9 +319,9 @@ func (p *Portfolio) Add(new Position) (bool, error) {
 			return false, errors.New("alredy exist")
 		}
 	}
-	// add to portfolio
+	// add to portfolio, reserving the position value as margin for both long and short
 	p.Open = append(p.Open, new)
-	fmt.Printf("added %s (len=%d) @%.8f isBuy:%v %v\n", new.symbol, len(p.Open), new.openPrice, new.isBuy, new.openTime)
+	p.Balance -= new.openPrice * new.size
 	return true, nil
 }
 
@@ -143,6 +375,7 @@ func (p *Position) Profit(price float64) float64 {
 		p.perc = p.openPrice / price
 		p.profit = (p.openPrice - price) * p.size
 	}
+	p.profit -= p.fee
 
 	// fmt.Println("--------")
 	// fmt.Println("openPrice", p.openPrice)
@@ -156,9 +389,9 @@ func (p *Position) Profit(price float64) float64 {
 	return p.profit
 }
 
-// close the index of given position
+// close the index of given position, releasing its margin and realizing profit
 func (p *Portfolio) Close(n int, closePrice float64, closeTime time.Time) bool {
-	if n > len(p.Open) {
+	if n < 0 || n >= len(p.Open) {
 		return false
 	}
 	pos := p.Open[n]
@@ -167,58 +400,96 @@ func (p *Portfolio) Close(n int, closePrice float64, closeTime time.Time) bool {
 	pos.profit = pos.Profit(closePrice)
 	pos.isClosed = true
 
+	p.Balance += pos.openPrice*pos.size + pos.profit
+	p.updateStats(pos.profit)
 	p.Closed = append(p.Closed, pos)
 	// p.Open = append(p.Open[:n], p.Open[n+1:]...)
 	p.Open = remove(p.Open, n)
-	fmt.Printf("closed pos=%d. %s @%.8f profit=%.2f\n", n, pos.symbol, pos.closePrice, pos.profit)
 	return true
 }
 
-// PortfolioTest strategies with fake proftfolio balance
-func (h *History) PortfolioTest(strategy Strategy, start, end time.Time) (Events, error) {
-	if len(h.bars) == 0 {
-		return nil, errors.New("no history")
-	}
-
-	var Wallet = new(Portfolio)
-
+// exits closes open positions of symbol whose stop or target is hit by bar,
+// and returns their close events. closing one side leaves nothing for the other
+func (p *Portfolio) exits(symbol string, bar Bar) Events {
 	var events Events
-	log.Printf("[BACKTEST] %s (start: %v ==> end: %v)\n", fmt.Sprintf("%T", strategy)[6:], start.Format(dt_stamp), end.Format(dt_stamp))
-
-	for symbol, bars := range h.bars {
-		for streamedBars := range bars.StreamInterval(start, end, bars.Period()) {
-			if event, ok := strategy.Run(symbol, streamedBars); ok {
-				ok := events.Add(event)
-				if !ok {
-					continue
-				}
-				// is a new event
-				price := event.Price
-				size := initial / price
-				pos := MakePosition(event, size)
-				added, err := Wallet.Add(pos)
-				// check many things
-				// 	log.Println("[BACKTEST] NewPosition added %s @%.8f %s\n", event.Symbol, event.Price, EventTypes[event.Type])
-				_ = price
-				_ = pos
-				_ = added
-				_ = err
+	for n := len(p.Open) - 1; n >= 0; n-- {
+		pos := p.Open[n]
+		if pos.symbol != symbol || !bar.Time.After(pos.openTime) {
+			continue
+		}
+		if price, name, ok := pos.exit(bar); ok {
+			p.Close(n, price, bar.Time)
+			event := NewEvent(symbol)
+			event.Name = name
+			event.Type = CLOSE_SELL
+			if pos.isBuy {
+				event.Type = CLOSE_BUY
 			}
+			event.Time = bar.Time
+			event.Price = price
+			events = append(events, event)
 		}
 	}
+	return events
+}
 
-	// fmt.Printf("%s\n", Wallet.Print())
-	var wins, total int
-	total = len(Wallet.Closed)
-	for _, po := range Wallet.Closed {
-		if po.profit > 0 {
-			wins++
+// apply opens a position of buy and sell events and closes one of close events.
+// events without size use the starting balance
+func (p *Portfolio) apply(event Event) {
+	switch event.Type {
+	case CLOSE_BUY, CLOSE_SELL:
+		if n, _ := p.Open.GetLastType(event.Symbol, event.Type == CLOSE_BUY); n >= 0 {
+			p.Close(n, event.Price, event.Time)
+		}
+	case MARKET_BUY, MARKET_SELL, LIMIT_BUY, LIMIT_SELL:
+		size := event.Size
+		if size == 0 && event.Price != 0 {
+			size = p.Initial() / event.Price
 		}
+		p.Add(MakePosition(event, size))
+	}
+}
+
+// PortfolioTest strategies with fake proftfolio balance, starting with the balance of
+// SetBalance. the result holds events of all symbols and the portfolio
+func (h *History) PortfolioTest(strategy Strategy, start, end time.Time) (*TestResult, error) {
+	h.RLock()
+	clock := h.clock
+	h.RUnlock()
+
+	return h.portfolioTest(strategy, start, end, clock)
+}
+
+// portfolioTest runs PortfolioTest with clock, nil clock is not set
+func (h *History) portfolioTest(strategy Strategy, start, end time.Time, clock Clock) (*TestResult, error) {
+	m := h.Map()
+	if len(m) == 0 {
+		return nil, errors.New("no history")
+	}
+
+	if ps, ok := strategy.(PortfolioStrategy); ok {
+		ps.Reset()
 	}
-	log.Printf("[BACKTEST] completed with %d Closed Events, wins=%d/%d ratio=%.1f%%\n", total, wins, total, 100*float64(wins)/float64(total))
 
-	_ = Wallet
-	return events, nil
+	h.RLock()
+	result := &TestResult{Portfolio: NewPortfolio(h.balance)}
+	h.RUnlock()
+	wallet := result.Portfolio
+
+	log.Printf("[BACKTEST] %s (start: %v ==> end: %v)\n", strategyName(strategy), start.Format(dt_stamp), end.Format(dt_stamp))
+
+	for _, symbol := range symbols(m) {
+		var events Events
+		result.Bars += runBars(strategy, symbol, m[symbol], start, end, &events, wallet, clock)
+		result.Events = append(result.Events, events...)
+	}
+	result.Events.SortByTime()
+
+	stats := wallet.Stats
+	log.Printf("[BACKTEST] completed with %d Closed Events, wins=%d/%d ratio=%.1f%%\n", stats.TotalTrades, stats.WinningTrades, stats.WinningTrades+stats.LosingTrades, stats.WinRate)
+	log.Printf("[BACKTEST] balance %.2f ==> %.2f (%.2f%%)\n", wallet.Initial(), wallet.Value(), wallet.Gain())
+
+	return result, nil
 }
 
 // remove slice element at index(s) and returns new slice
@@ -0,0 +1,262 @@
+package history
+
+import (
+	"math"
+	"reflect"
+	"testing"
+	"time"
+)
+
+// bracketOnce buys once on the fifth bar with a stop and target
+type bracketOnce struct{}
+
+func (bracketOnce) Run(symbol string, bars Bars) (Event, bool) {
+	if len(bars) != 5 {
+		return Event{}, false
+	}
+	c := bars[0].Close
+	return BuyBracket(symbol, bars[0].Time, 1, c, c-10, c+3), true
+}
+
+func TestTestSymbolMatchesPortfolioTest(t *testing.T) {
+	h := newTestHistory(t)
+	h.SetPersist(false)
+	h.Add("BTCUSDT1h", makeBars(20, time.Hour))
+
+	all, err := h.PortfolioTest(bracketOnce{}, h.FirstTime(), h.LastTime())
+	if err != nil {
+		t.Fatal(err)
+	}
+	events := all.Events
+	result, err := h.TestSymbol(bracketOnce{}, "BTCUSDT1h", h.FirstTime(), h.LastTime())
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if !reflect.DeepEqual(result.Events, events) {
+		t.Fatalf("TestSymbol events %v, PortfolioTest events %v", result.Events, events)
+	}
+	if len(events) != 2 || events[1].Name != "Target" {
+		t.Fatalf("want entry and target exit, got %v", events)
+	}
+	p := result.Portfolio
+	if p.Stats.TotalTrades != 1 || len(p.Open) != 0 || p.Stats.GrossProfit != 3 {
+		t.Fatalf("want one closed trade of 3 profit, got %+v open=%d", p.Stats, len(p.Open))
+	}
+}
+
+func TestGainCountsOpenPositionsAtCost(t *testing.T) {
+	p := NewPortfolio(1000)
+	p.apply(BuyBracket("BTCUSDT1h", start, 5, 100, 0, 0))
+
+	if p.Balance != 500 {
+		t.Fatalf("balance %v, want 500", p.Balance)
+	}
+	if p.Value() != 1000 || p.Gain() != 0 {
+		t.Fatalf("value %v gain %v, want 1000 and 0", p.Value(), p.Gain())
+	}
+}
+
+// buyThenSell buys on the fifth bar and closes on the tenth
+type buyThenSell struct{}
+
+func (buyThenSell) Run(symbol string, bars Bars) (Event, bool) {
+	event := NewEvent(symbol)
+	event.Time = bars[0].Time
+	event.Price = bars[0].Close
+	event.Size = 10
+	switch len(bars) {
+	case 5:
+		event.Type = MARKET_BUY
+	case 10:
+		event.Type = CLOSE_BUY
+	default:
+		return Event{}, false
+	}
+	return event, true
+}
+
+func TestPortfolioTestFinalBalance(t *testing.T) {
+	h := newTestHistory(t)
+	h.SetPersist(false)
+	h.SetBalance(2000)
+	h.Add("BTCUSDT1h", makeBars(20, time.Hour))
+
+	result, err := h.PortfolioTest(buyThenSell{}, h.FirstTime(), h.LastTime())
+	if err != nil {
+		t.Fatal(err)
+	}
+	p := result.Portfolio
+	if p == nil || p.Initial() != 2000 {
+		t.Fatalf("want portfolio starting at 2000, got %v", p)
+	}
+	// bought 10 at 104, sold at 109
+	if p.Value() != 2050 || math.Abs(p.Gain()-2.5) > 1e-9 {
+		t.Fatalf("value %v gain %v%%, want 2050 and 2.5%%", p.Value(), p.Gain())
+	}
+	if result.Bars != 20 {
+		t.Fatalf("ran on %d bars, want 20", result.Bars)
+	}
+}
+
+func TestShortGainsWhenPriceFalls(t *testing.T) {
+	// without size the position is worth the starting balance of 1000
+	p := NewPortfolio(1000)
+	short := NewEvent("BTCUSDT1h")
+	short.Type, short.Time, short.Price = MARKET_SELL, start, 100
+	p.apply(short)
+	if p.Balance != 0 || p.Value() != 1000 {
+		t.Fatalf("balance %v value %v, want 1000 reserved as margin", p.Balance, p.Value())
+	}
+
+	cover := NewEvent("BTCUSDT1h")
+	cover.Type, cover.Time, cover.Price = CLOSE_SELL, start.Add(time.Hour), 90
+	p.apply(cover)
+	if len(p.Open) != 0 || math.Abs(p.Balance-1100) > 1e-9 || math.Abs(p.Stats.GrossProfit-100) > 1e-9 {
+		t.Fatalf("balance %v profit %v, want 1100 and 100", p.Balance, p.Stats.GrossProfit)
+	}
+}
+
+// statsOf returns stats of closed trades with profits
+func statsOf(profits ...float64) PortfolioStats {
+	p := NewPortfolio(1000)
+	for _, profit := range profits {
+		p.updateStats(profit)
+	}
+	return p.Stats
+}
+
+func TestStatsBreakEvenTrades(t *testing.T) {
+	s := statsOf(10, 0, -5, 0)
+	if s.TotalTrades != 4 || s.WinningTrades != 1 || s.LosingTrades != 1 {
+		t.Fatalf("got %+v, want 4 trades with 1 win and 1 loss", s)
+	}
+	// break-even trades are not part of the win rate
+	if s.WinRate != 50 {
+		t.Fatalf("win rate %v, want 50", s.WinRate)
+	}
+}
+
+func TestStatsKnownTrades(t *testing.T) {
+	s := statsOf(30, -10, 20, -20)
+	if s.GrossProfit != 50 || s.GrossLoss != 30 {
+		t.Fatalf("gross profit %v loss %v, want 50 and 30", s.GrossProfit, s.GrossLoss)
+	}
+	if math.Abs(s.ProfitFactor-50./30) > 1e-9 || s.Expectancy != 5 {
+		t.Fatalf("profit factor %v expectancy %v, want 1.67 and 5", s.ProfitFactor, s.Expectancy)
+	}
+	if s.AvgWin != 25 || s.AvgLoss != 15 {
+		t.Fatalf("avg win %v loss %v, want 25 and 15", s.AvgWin, s.AvgLoss)
+	}
+	// no losses leaves profit factor at 0
+	if s := statsOf(10, 5); s.ProfitFactor != 0 {
+		t.Fatalf("profit factor without losses %v, want 0", s.ProfitFactor)
+	}
+}
+
+func TestStatsStreaks(t *testing.T) {
+	s := statsOf(1, 1, -1, -1, -1, 0, -1, 1, 1, 1, 1, -1)
+	if s.MaxConsecutiveWins != 4 || s.MaxConsecutiveLosses != 3 {
+		t.Fatalf("streaks %d wins %d losses, want 4 and 3", s.MaxConsecutiveWins, s.MaxConsecutiveLosses)
+	}
+}
+
+// buyOnce buys on the first bar it sees while its own portfolio is flat
+type buyOnce struct {
+	p      *Portfolio
+	resets int
+}
+
+func (s *buyOnce) Run(symbol string, bars Bars) (Event, bool) {
+	if len(s.p.Open) > 0 {
+		return Event{}, false
+	}
+	event := BuyBracket(symbol, bars[0].Time, 1, bars[0].Close, 0, 0)
+	s.p.apply(event)
+	return event, true
+}
+
+func (s *buyOnce) Reset() {
+	s.resets++
+	s.p.Reset()
+}
+
+func TestPortfolioStrategyIsReset(t *testing.T) {
+	h := newTestHistory(t)
+	h.SetPersist(false)
+	h.Add("BTCUSDT1h", makeBars(10, time.Hour))
+	s := &buyOnce{p: NewPortfolio(1000)}
+
+	first, err := h.TestSymbol(s, "BTCUSDT1h", h.FirstTime(), h.LastTime())
+	if err != nil {
+		t.Fatal(err)
+	}
+	second, err := h.TestSymbol(s, "BTCUSDT1h", h.FirstTime(), h.LastTime())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if s.resets != 2 || len(first.Events) != 1 || !reflect.DeepEqual(first.Events, second.Events) {
+		t.Fatalf("resets %d, events %v then %v", s.resets, first.Events, second.Events)
+	}
+	if s.p.Balance != 1000-100 || s.p.Initial() != 1000 {
+		t.Fatalf("balance %v initial %v after reset and one buy", s.p.Balance, s.p.Initial())
+	}
+}
+
+func TestPositionSizing(t *testing.T) {
+	p := NewPortfolio(10000)
+	// 1% of 10000 lost over a stop 5 below entry
+	if got := p.RiskSize(100, 95, 1); got != 20 {
+		t.Fatalf("RiskSize %v, want 20", got)
+	}
+	// shorts risk the same distance above entry
+	if got := p.RiskSize(100, 105, 1); got != 20 {
+		t.Fatalf("RiskSize of short %v, want 20", got)
+	}
+	if got := p.RiskSize(100, 100, 1); got != 0 {
+		t.Fatalf("RiskSize without stop distance %v, want 0", got)
+	}
+	if got := p.FixedFractionalSize(50, 10); got != 20 {
+		t.Fatalf("FixedFractionalSize %v, want 20", got)
+	}
+}
+
+func TestBracketExits(t *testing.T) {
+	bar := func(high, low float64) Bar {
+		return Bar{Time: start.Add(time.Hour), Open: 100, High: high, Low: low, Close: 100}
+	}
+	for _, tc := range []struct {
+		name  string
+		entry Event
+		bar   Bar
+		exit  string
+		price float64
+	}{
+		{"long stop", BuyBracket("BTCUSDT1h", start, 1, 100, 95, 110), bar(101, 94), "Stop", 95},
+		{"long target", BuyBracket("BTCUSDT1h", start, 1, 100, 95, 110), bar(111, 99), "Target", 110},
+		{"long both hit stops first", BuyBracket("BTCUSDT1h", start, 1, 100, 95, 110), bar(111, 94), "Stop", 95},
+		{"short stop", SellBracket("BTCUSDT1h", start, 1, 100, 105, 90), bar(106, 99), "Stop", 105},
+		{"short target", SellBracket("BTCUSDT1h", start, 1, 100, 105, 90), bar(101, 89), "Target", 90},
+		{"inside", BuyBracket("BTCUSDT1h", start, 1, 100, 95, 110), bar(109, 96), "", 0},
+	} {
+		p := NewPortfolio(1000)
+		p.apply(tc.entry)
+		events := p.exits("BTCUSDT1h", tc.bar)
+		if tc.exit == "" {
+			if len(events) != 0 || len(p.Open) != 1 {
+				t.Errorf("%s: got exits %v", tc.name, events)
+			}
+			continue
+		}
+		if len(events) != 1 || events[0].Name != tc.exit || events[0].Price != tc.price || len(p.Open) != 0 {
+			t.Errorf("%s: got exits %v, want %s at %v", tc.name, events, tc.exit, tc.price)
+		}
+	}
+
+	// no exit on the bar the position opened on
+	p := NewPortfolio(1000)
+	p.apply(BuyBracket("BTCUSDT1h", start, 1, 100, 95, 110))
+	if events := p.exits("BTCUSDT1h", Bar{Time: start, High: 120, Low: 80}); len(events) != 0 {
+		t.Errorf("exit on entry bar %v", events)
+	}
+}
@@ -9,7 +9,7 @@ import (
 	"time"
 )
 
-// Bar
+// Bar, Time is in UTC
 type Bar struct {
 	Time   time.Time `json:"time"`
 	Open   float64   `json:"open"`
@@ -17,6 +17,9 @@ type Bar struct {
 	Low    float64   `json:"low"`
 	Close  float64   `json:"close"`
 	Volume float64   `json:"volume,omitempty"`
+	// QuoteVolume and Trades are optional, zero if the source has none
+	QuoteVolume float64
+	Trades      int64
 }
 
 func (b Bar) MarshalJSON() ([]byte, error) {
@@ -28,6 +31,12 @@ func (b Bar) MarshalJSON() ([]byte, error) {
 		"Close":  b.Close,
 		"Volume": b.Volume,
 	}
+	if b.QuoteVolume != 0 {
+		m["QuoteVolume"] = b.QuoteVolume
+	}
+	if b.Trades != 0 {
+		m["Trades"] = b.Trades
+	}
 
 	return json.Marshal(m)
 }
@@ -39,7 +48,11 @@ func (b *Bar) UnmarshalJSON(data []byte) error {
 		return err
 	}
 
-	b.Time = time.Unix(int64(m["Time"].(float64)), 0)
+	t, ok := m["Time"].(float64)
+	if !ok {
+		return fmt.Errorf("bar Time is missing or not a number: %v", m["Time"])
+	}
+	b.Time = time.Unix(int64(t), 0).UTC()
 	if b.Open, err = strconv.ParseFloat(fmt.Sprintf("%v", m["Open"]), 64); err != nil {
 		return err
 	}
@@ -55,6 +68,12 @@ func (b *Bar) UnmarshalJSON(data []byte) error {
 	if b.Volume, err = strconv.ParseFloat(fmt.Sprintf("%v", m["Volume"]), 64); err != nil {
 		return err
 	}
+	if v, ok := m["QuoteVolume"].(float64); ok {
+		b.QuoteVolume = v
+	}
+	if v, ok := m["Trades"].(float64); ok {
+		b.Trades = int64(v)
+	}
 
 	return err
 }
@@ -110,7 +129,7 @@ const (
 	h4  Timeframe = 240
 	h6  Timeframe = 360
 	h8  Timeframe = 480
-	h12 Timeframe = 960
+	h12 Timeframe = 720
 	d1  Timeframe = 1440
 	d3  Timeframe = 4320
 	w1  Timeframe = 10080
@@ -138,7 +157,7 @@ func TFInterval(tf string) Timeframe {
 		return h6
 	case "8h", "8H", "h8", "H8", "480":
 		return h8
-	case "12h", "12H", "h12", "H12", "960":
+	case "12h", "12H", "h12", "H12", "720":
 		return h12
 	case "1d", "1D", "d1", "D1", "d", "D", "1440":
 		return d1
@@ -194,6 +213,20 @@ func TFIsValid(tf string) bool {
 	return TFString(TFInterval(tf)) != ""
 }
 
+// Duration of timeframe
+func (tf Timeframe) Duration() time.Duration {
+	return time.Duration(tf) * time.Minute
+}
+
+// DurationToTimeframe returns the defined timeframe of duration d, or 0 if there is none
+func DurationToTimeframe(d time.Duration) Timeframe {
+	tf := Timeframe(d / time.Minute)
+	if d%time.Minute != 0 || TFString(tf) == "" {
+		return 0
+	}
+	return tf
+}
+
 // T returns bar time
 func (b Bar) T() time.Time {
 	return b.Time
@@ -271,12 +304,30 @@ func (b Bar) Bear() bool {
 
 // Bullish that  closes upper 33%
 func (b Bar) Bullish() bool {
-	return b.Close >= (b.High - b.Range()/3)
+	return b.ClosesInTop(1. / 3)
 }
 
 // Bearish that  closes bottom 33%
 func (b Bar) Bearish() bool {
-	return b.Close <= (b.Low + b.Range()/3)
+	return b.ClosesInBottom(1. / 3)
+}
+
+// ClosesInTop returns true if bar closes in the top frac (0..1) of its range,
+// false for bars without range
+func (b Bar) ClosesInTop(frac float64) bool {
+	if b.Range() <= 0 {
+		return false
+	}
+	return b.Close >= b.High-b.Range()*frac
+}
+
+// ClosesInBottom returns true if bar closes in the bottom frac (0..1) of its range,
+// false for bars without range
+func (b Bar) ClosesInBottom(frac float64) bool {
+	if b.Range() <= 0 {
+		return false
+	}
+	return b.Close <= b.Low+b.Range()*frac
 }
 
 // WickUp
@@ -0,0 +1,42 @@
+package history
+
+import (
+	"testing"
+	"time"
+)
+
+func TestTimeframeDuration(t *testing.T) {
+	for _, tc := range []struct {
+		tf string
+		d  time.Duration
+	}{
+		{"1m", time.Minute},
+		{"3m", 3 * time.Minute},
+		{"5m", 5 * time.Minute},
+		{"15m", 15 * time.Minute},
+		{"30m", 30 * time.Minute},
+		{"1h", time.Hour},
+		{"4h", 4 * time.Hour},
+		{"6h", 6 * time.Hour},
+		{"8h", 8 * time.Hour},
+		{"12h", 12 * time.Hour},
+		{"1d", 24 * time.Hour},
+		{"3d", 3 * 24 * time.Hour},
+		{"1w", 7 * 24 * time.Hour},
+		// a month has no fixed duration, only check the round trip
+		{"M", 0},
+	} {
+		d := TFInterval(tc.tf).Duration()
+		if tc.d != 0 && d != tc.d {
+			t.Errorf("%s is %v, want %v", tc.tf, d, tc.d)
+		}
+		if got := TFString(DurationToTimeframe(d)); got != tc.tf {
+			t.Errorf("%s is %v, back to %q", tc.tf, d, got)
+		}
+	}
+	for _, d := range []time.Duration{7 * time.Minute, 90 * time.Second, 16 * time.Hour, 0} {
+		if tf := DurationToTimeframe(d); tf != 0 {
+			t.Errorf("DurationToTimeframe(%v) = %v, want 0", d, tf)
+		}
+	}
+}
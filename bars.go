@@ -1,6 +1,7 @@
 package history
 
 import (
+	"fmt"
 	"sort"
 	"time"
 )
@@ -33,6 +34,127 @@ func (bars Bars) Reverse() Bars {
 	return rev
 }
 
+// Slice returns bars[from:to] with indexes clamped to valid bounds,
+// empty bars if the range is empty
+func (bars Bars) Slice(from, to int) Bars {
+	if from < 0 {
+		from = 0
+	}
+	if to > len(bars) {
+		to = len(bars)
+	}
+	if from >= to {
+		return Bars{}
+	}
+	return bars[from:to]
+}
+
+// Window returns the n most recent bars, or all if there are less
+func (bars Bars) Window(n int) Bars {
+	return bars.Slice(0, n)
+}
+
+// Since returns bars within d of the last bar time, as a sub-slice of bars
+func (bars Bars) Since(d time.Duration) Bars {
+	if len(bars) == 0 {
+		return bars
+	}
+	from := bars.LastBar().T().Add(-d)
+	// bars are newest first, find the first bar before from
+	n := sort.Search(len(bars), func(i int) bool { return bars[i].T().Before(from) })
+	return bars[:n]
+}
+
+// Downsample returns at most maxPoints bars spanning all bars, by aggregating
+// bars in equal sized buckets to OHLC bars. first and last bars are kept as is.
+// bucket bars have the time of their newest bar, see DownsampleIndex
+func (bars Bars) Downsample(maxPoints int) Bars {
+	if maxPoints < 3 || len(bars) <= maxPoints {
+		return bars
+	}
+
+	idx := bars.DownsampleIndex(maxPoints)
+	down := make(Bars, 0, len(idx))
+	down = append(down, bars[0])
+	for i := 1; i < len(idx)-1; i++ {
+		bucket := bars[idx[i]:idx[i+1]]
+		b := bucket.FirstBar()
+		b.Time = bucket.LastBar().Time
+		b.Close = bucket.LastBar().Close
+		b.High = bucket.Highest(H)
+		b.Low = bucket.Lowest(L)
+		b.Volume, b.QuoteVolume, b.Trades = 0, 0, 0
+		for _, bar := range bucket {
+			b.Volume += bar.Volume
+			b.QuoteVolume += bar.QuoteVolume
+			b.Trades += bar.Trades
+		}
+		down = append(down, b)
+	}
+	down = append(down, bars[len(bars)-1])
+
+	return down
+}
+
+// DownsampleIndex returns for every bar of Downsample the index of its newest bar in bars,
+// so indicators computed on all bars can be picked for the downsampled bars
+func (bars Bars) DownsampleIndex(maxPoints int) []int {
+	if maxPoints < 3 || len(bars) <= maxPoints {
+		idx := make([]int, len(bars))
+		for i := range idx {
+			idx[i] = i
+		}
+		return idx
+	}
+
+	// bars between newest and oldest in buckets
+	inner := len(bars) - 2
+	buckets := maxPoints - 2
+
+	idx := make([]int, 0, maxPoints)
+	idx = append(idx, 0)
+	for n := 0; n < buckets; n++ {
+		idx = append(idx, 1+n*inner/buckets)
+	}
+	return append(idx, len(bars)-1)
+}
+
+// Resample aggregates bars to OHLC bars of timeframe tf, aligned to UTC.
+// the oldest and newest bars can be partial if bars do not cover them
+func (bars Bars) Resample(tf Timeframe) Bars {
+	d := tf.Duration()
+	if d <= 0 || len(bars) == 0 {
+		return Bars{}
+	}
+
+	// bars are newest first, walk from the oldest
+	var resampled Bars
+	for i := len(bars) - 1; i >= 0; i-- {
+		bar := bars[i]
+		t := bar.Time.Truncate(d)
+
+		if len(resampled) == 0 || !resampled[len(resampled)-1].Time.Equal(t) {
+			bar.Time = t
+			resampled = append(resampled, bar)
+			continue
+		}
+
+		b := &resampled[len(resampled)-1]
+		b.Close = bar.Close
+		if bar.High > b.High {
+			b.High = bar.High
+		}
+		if bar.Low < b.Low {
+			b.Low = bar.Low
+		}
+		b.Volume += bar.Volume
+		b.QuoteVolume += bar.QuoteVolume
+		b.Trades += bar.Trades
+	}
+
+	return resampled.Reverse()
+}
+
 // Period returns the calculated timeframe interval,
 // need at least 2 bars or it will return 1 minute as default
 func (bars Bars) Period() time.Duration {
@@ -61,7 +183,7 @@ func (bars Bars) LastBar() Bar {
 	return bars[0]
 }
 
-// Find Bar for given time
+// Find Bar for given time, bars must be sorted (newest first)
 func (bars Bars) Find(dt time.Time) (n int, bar Bar) {
 	if 1 > len(bars) {
 		return -1, Bar{}
@@ -70,10 +192,12 @@ func (bars Bars) Find(dt time.Time) (n int, bar Bar) {
 		return -1, Bar{}
 	}
 
-	for i, b := range bars {
-		if b.T() == dt {
-			return i, b
-		}
+	// first bar at or before dt
+	i := sort.Search(len(bars), func(i int) bool {
+		return !bars[i].T().After(dt)
+	})
+	if i < len(bars) && bars[i].T().Equal(dt) {
+		return i, bars[i]
 	}
 
 	return -1, Bar{}
@@ -93,23 +217,110 @@ func (bars Bars) TimeSpan(start, end time.Time) Bars {
 	return span
 }
 
-// merges bars
-func merge(old, new Bars) Bars {
-	if len(old) == 0 {
-		return new
+// Validate returns all anomalies found in bars
+func (bars Bars) Validate() []error {
+	var errs []error
+
+	for i, b := range bars {
+		t := b.Time.Format(time.RFC3339)
+		if b.Open <= 0 || b.High <= 0 || b.Low <= 0 || b.Close <= 0 {
+			errs = append(errs, fmt.Errorf("bar %d (%s): non-positive price", i, t))
+		}
+		if b.High < b.Low {
+			errs = append(errs, fmt.Errorf("bar %d (%s): high < low", i, t))
+		}
+		if b.High < b.Open || b.High < b.Close {
+			errs = append(errs, fmt.Errorf("bar %d (%s): high < open/close", i, t))
+		}
+		if b.Low > b.Open || b.Low > b.Close {
+			errs = append(errs, fmt.Errorf("bar %d (%s): low > open/close", i, t))
+		}
+		if i+1 < len(bars) {
+			if b.Time.Equal(bars[i+1].Time) {
+				errs = append(errs, fmt.Errorf("bar %d (%s): duplicate time", i, t))
+			} else if b.Time.Before(bars[i+1].Time) {
+				errs = append(errs, fmt.Errorf("bar %d (%s): not sorted", i, t))
+			}
+		}
 	}
 
-	first := old.FirstBar().T()
-	last := old.LastBar().T()
+	return errs
+}
+
+// Gap of missing bars between two bars
+type Gap struct {
+	From, To time.Time
+	Missing  int
+}
 
-	merged := old
-	for _, b := range new {
-		if b.T().After(last) || b.T().Before(first) {
-			merged = append(merged, b)
+// Gaps returns missing bars between bars spaced more then Period apart (newest first),
+// bars must be sorted
+func (bars Bars) Gaps() []Gap {
+	var gaps []Gap
+	period := bars.Period()
+	if period <= 0 {
+		return gaps
+	}
+
+	for i := 0; i < len(bars)-1; i++ {
+		d := bars[i].Time.Sub(bars[i+1].Time)
+		if d > period {
+			gaps = append(gaps, Gap{From: bars[i+1].Time, To: bars[i].Time, Missing: int(d/period) - 1})
 		}
 	}
+	return gaps
+}
+
+// Clean returns sorted bars without duplicate times, keeping the latest added bar
+func (bars Bars) Clean() Bars {
+	last := make(map[int64]int, len(bars))
+	for i, b := range bars {
+		last[b.Time.Unix()] = i
+	}
+
+	clean := make(Bars, 0, len(last))
+	for i, b := range bars {
+		if last[b.Time.Unix()] == i {
+			clean = append(clean, b)
+		}
+	}
+
+	return clean.Sort()
+}
+
+// Merge returns bars merged with other by time, other takes precedence on
+// bars with the same time. both must be sorted (newest first)
+func (bars Bars) Merge(other Bars) Bars {
+	if len(bars) == 0 {
+		return other
+	}
+	if len(other) == 0 {
+		return bars
+	}
+
+	merged := make(Bars, 0, len(bars)+len(other))
+	i, j := 0, 0
+	for i < len(bars) && j < len(other) {
+		switch {
+		case bars[i].Time.After(other[j].Time):
+			merged = append(merged, bars[i])
+			i++
+		case other[j].Time.After(bars[i].Time):
+			merged = append(merged, other[j])
+			j++
+		default:
+			merged = append(merged, other[j])
+			i++
+			j++
+		}
+	}
+	merged = append(merged, bars[i:]...)
+	merged = append(merged, other[j:]...)
 
-	// sort it
-	merged = merged.Sort()
 	return merged
 }
+
+// merges bars, new bars overwrites old bars with the same time
+func merge(old, new Bars) Bars {
+	return old.Sort().Merge(new.Sort())
+}
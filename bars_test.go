@@ -0,0 +1,99 @@
+package history
+
+import (
+	"reflect"
+	"testing"
+	"time"
+)
+
+func TestMergeOverlapInTheMiddle(t *testing.T) {
+	bars := makeBars(10, time.Hour)
+	// bars 3 to 6 hours from start with new closes
+	other := makeBars(10, time.Hour, 50)[3:7]
+
+	merged := bars.Merge(other)
+	if len(merged) != 10 {
+		t.Fatalf("got %d bars, want 10", len(merged))
+	}
+	for i, bar := range merged {
+		want := bars[i]
+		if i >= 3 && i < 7 {
+			want = other[i-3]
+		}
+		if bar != want {
+			t.Fatalf("bar %d is %v, want %v", i, bar, want)
+		}
+	}
+}
+
+func TestMergeFullReplace(t *testing.T) {
+	bars := makeBars(10, time.Hour)
+	other := makeBars(10, time.Hour, 50)
+
+	if merged := bars.Merge(other); !reflect.DeepEqual(merged, other) {
+		t.Fatalf("got %v, want %v", merged, other)
+	}
+}
+
+func TestFind(t *testing.T) {
+	bars := makeBars(100, time.Hour)
+	for _, i := range []int{0, 37, 99} {
+		if n, bar := bars.Find(bars[i].Time); n != i || bar != bars[i] {
+			t.Errorf("Find bar %d got %d", i, n)
+		}
+	}
+	if n, _ := bars.Find(start.Add(90 * time.Minute)); n != -1 {
+		t.Errorf("Find between bars got %d, want -1", n)
+	}
+}
+
+func BenchmarkFind(b *testing.B) {
+	bars := makeBars(10000, time.Minute)
+	dt := bars[7777].Time
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		bars.Find(dt)
+	}
+}
+
+// BenchmarkFindLinear is the linear scan Find replaced, as a baseline
+func BenchmarkFindLinear(b *testing.B) {
+	bars := makeBars(10000, time.Minute)
+	dt := bars[7777].Time
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		for n := range bars {
+			if bars[n].Time.Equal(dt) {
+				break
+			}
+		}
+	}
+}
+
+func TestSince(t *testing.T) {
+	bars := makeBars(48, time.Hour)
+	// the last bar and the 3 before it are within 3 hours
+	if got := bars.Since(3 * time.Hour); len(got) != 4 || got[0] != bars[0] {
+		t.Fatalf("got %d bars, want 4 newest", len(got))
+	}
+	if got := bars.Since(100 * time.Hour); len(got) != 48 {
+		t.Fatalf("got %d bars, want all 48", len(got))
+	}
+	if got := (Bars{}).Since(time.Hour); len(got) != 0 {
+		t.Fatalf("got %d bars of empty bars", len(got))
+	}
+}
+
+func TestDownsampleIndex(t *testing.T) {
+	bars := makeBars(1000, time.Hour)
+	down := bars.Downsample(100)
+	idx := bars.DownsampleIndex(100)
+	if len(down) != 100 || len(idx) != len(down) {
+		t.Fatalf("got %d bars and %d indexes, want 100", len(down), len(idx))
+	}
+	for i := range down {
+		if !bars[idx[i]].T().Equal(down[i].T()) {
+			t.Fatalf("bar %d: index time %v, downsampled time %v", i, bars[idx[i]].T(), down[i].T())
+		}
+	}
+}
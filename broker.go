@@ -0,0 +1,113 @@
+package history
+
+import (
+	"errors"
+	"fmt"
+	"sync"
+	"time"
+)
+
+// Broker interface plugs order execution of events
+type Broker interface {
+	PlaceOrder(Event) (Fill, error)
+}
+
+// Fill of an order
+type Fill struct {
+	Symbol string
+	Type   EventType
+	Price  float64
+	Size   float64
+	Time   time.Time
+	Fee    float64
+}
+
+// PaperBroker fills orders at event price. Portfolio sizes orders and is not
+// changed by PlaceOrder, add fills to it with AddFill (EventListener does)
+type PaperBroker struct {
+	Portfolio *Portfolio
+	// Fee in percent of order value
+	Fee float64
+	// Clock stamps orders without time, nil uses real time
+	Clock Clock
+
+	mu sync.Mutex
+}
+
+// NewPaperBroker returns a PaperBroker sizing orders with a portfolio starting with balance
+func NewPaperBroker(balance, fee float64) *PaperBroker {
+	return &PaperBroker{Portfolio: NewPortfolio(balance), Fee: fee}
+}
+
+// PlaceOrder fills event. buy and sell events without size use the starting balance,
+// close events without size take the size of the oldest open position of that side
+func (b *PaperBroker) PlaceOrder(event Event) (Fill, error) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	if b.Portfolio == nil {
+		b.Portfolio = NewPortfolio(0)
+	}
+	if event.Price <= 0 {
+		return Fill{}, errors.New("price is missing")
+	}
+	t := event.Time
+	if t.IsZero() {
+		t = realClock{}.Now()
+		if b.Clock != nil {
+			t = b.Clock.Now()
+		}
+		t = t.UTC()
+	}
+	fill := Fill{Symbol: event.Symbol, Type: event.Type, Price: event.Price, Size: event.Size, Time: t}
+
+	switch event.Type {
+	case MARKET_BUY, MARKET_SELL, LIMIT_BUY, LIMIT_SELL:
+		if fill.Size == 0 {
+			fill.Size = b.Portfolio.Initial() / fill.Price
+		}
+
+	case CLOSE_BUY, CLOSE_SELL:
+		n, pos := b.Portfolio.Open.GetLastType(event.Symbol, event.Type == CLOSE_BUY)
+		if n < 0 {
+			return Fill{}, fmt.Errorf("no open position for %s", event.Symbol)
+		}
+		if fill.Size == 0 {
+			fill.Size = pos.size
+		}
+
+	default:
+		return Fill{}, fmt.Errorf("can not place %s order", EventTypes[event.Type])
+	}
+
+	fill.Fee = fill.Price * fill.Size * b.Fee / 100
+	return fill, nil
+}
+
+// AddFill opens a position of a buy or sell fill, or closes the oldest open position
+// of that side with a close fill. fees are taken from the profit of the position
+func (p *Portfolio) AddFill(fill Fill) error {
+	switch fill.Type {
+	case MARKET_BUY, MARKET_SELL, LIMIT_BUY, LIMIT_SELL:
+		pos := Position{
+			symbol:    fill.Symbol,
+			isBuy:     fill.Type == MARKET_BUY || fill.Type == LIMIT_BUY,
+			openTime:  fill.Time,
+			openPrice: fill.Price,
+			size:      fill.Size,
+			fee:       fill.Fee,
+		}
+		_, err := p.Add(pos)
+		return err
+
+	case CLOSE_BUY, CLOSE_SELL:
+		n, _ := p.Open.GetLastType(fill.Symbol, fill.Type == CLOSE_BUY)
+		if n < 0 {
+			return fmt.Errorf("no open position for %s", fill.Symbol)
+		}
+		p.Open[n].fee += fill.Fee
+		p.Close(n, fill.Price, fill.Time)
+		return nil
+	}
+	return fmt.Errorf("can not fill %s order", EventTypes[fill.Type])
+}
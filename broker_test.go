@@ -0,0 +1,54 @@
+package history
+
+import (
+	"math"
+	"testing"
+	"time"
+)
+
+// buyThenClose buys on the fifth bar and closes on the sixth
+type buyThenClose struct{}
+
+func (buyThenClose) Run(symbol string, bars Bars) (Event, bool) {
+	event := NewEvent(symbol)
+	event.Time = bars[0].Time
+	event.Price = bars[0].Close
+	event.Size = 10
+	switch len(bars) {
+	case 5:
+		event.Type = MARKET_BUY
+	case 6:
+		event.Type = CLOSE_BUY
+	default:
+		return Event{}, false
+	}
+	return event, true
+}
+
+func TestListenerFillsFeedPortfolio(t *testing.T) {
+	h := newTestHistory(t)
+	h.SetPersist(false)
+	bars := makeBars(6, time.Hour)
+	h.Add("BTCUSDT1h", bars[2:])
+
+	broker := NewPaperBroker(1000, 0.1)
+	var e EventListener
+	e.Add(buyThenClose{})
+	e.SetBroker(broker, broker.Portfolio)
+	// run as the listener does on every update
+	var events Events
+	for i := 1; i >= 0; i-- {
+		h.Add("BTCUSDT1h", bars[i:i+1])
+		e.run(h, "BTCUSDT1h", &events)
+	}
+
+	p := broker.Portfolio
+	if len(p.Open) != 0 || p.Stats.TotalTrades != 1 {
+		t.Fatalf("want one closed trade, got %+v open=%d", p.Stats, len(p.Open))
+	}
+	// bought 10 at 104 and sold at 105, less 0.1% fee on both
+	want := 10 - 1.04 - 1.05
+	if math.Abs(p.Stats.GrossProfit-want) > 1e-9 || math.Abs(p.Balance-1000-want) > 1e-9 {
+		t.Fatalf("profit %v balance %v, want profit %v", p.Stats.GrossProfit, p.Balance, want)
+	}
+}
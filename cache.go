@@ -0,0 +1,126 @@
+package history
+
+import (
+	"container/list"
+	"strings"
+	"sync"
+)
+
+// barCache is a least recently used cache of stored bars by symbol
+type barCache struct {
+	size  int
+	ll    *list.List
+	items map[string]*list.Element
+	// gen counts invalidations by symbol, so reads started before one are not cached
+	gen map[string]uint64
+
+	sync.Mutex
+}
+
+// cacheEntry in barCache
+type cacheEntry struct {
+	symbol string
+	bars   Bars
+}
+
+// newBarCache returns cache holding up to size symbols
+func newBarCache(size int) *barCache {
+	return &barCache{size: size, ll: list.New(), items: make(map[string]*list.Element), gen: make(map[string]uint64)}
+}
+
+// get bars of symbol and mark it recently used
+func (c *barCache) get(symbol string) (Bars, bool) {
+	c.Lock()
+	defer c.Unlock()
+
+	e, ok := c.items[symbol]
+	if !ok {
+		return nil, false
+	}
+	c.ll.MoveToFront(e)
+	return e.Value.(*cacheEntry).bars, true
+}
+
+// generation of symbol, take it before reading bars to put
+func (c *barCache) generation(symbol string) uint64 {
+	c.Lock()
+	defer c.Unlock()
+	return c.gen[symbol]
+}
+
+// put bars of symbol read at generation gen, removing the least recently used
+// symbol when full. bars are dropped if symbol was invalidated since gen
+func (c *barCache) put(symbol string, bars Bars, gen uint64) {
+	c.Lock()
+	defer c.Unlock()
+
+	if c.gen[symbol] != gen {
+		return
+	}
+
+	if e, ok := c.items[symbol]; ok {
+		e.Value.(*cacheEntry).bars = bars
+		c.ll.MoveToFront(e)
+		return
+	}
+	c.items[symbol] = c.ll.PushFront(&cacheEntry{symbol, bars})
+
+	if c.ll.Len() > c.size {
+		e := c.ll.Back()
+		c.ll.Remove(e)
+		delete(c.items, e.Value.(*cacheEntry).symbol)
+	}
+}
+
+// remove symbol from cache and start a new generation
+func (c *barCache) remove(symbol string) {
+	c.Lock()
+	defer c.Unlock()
+
+	c.gen[symbol]++
+	if e, ok := c.items[symbol]; ok {
+		c.ll.Remove(e)
+		delete(c.items, symbol)
+	}
+}
+
+// SetCache caches ReadBars of up to size symbols, 0 disables the cache
+func (h *History) SetCache(size int) {
+	h.Lock()
+	defer h.Unlock()
+
+	h.cache = nil
+	if size > 0 {
+		h.cache = newBarCache(size)
+	}
+}
+
+// ReadBars reads stored bars of symbol through the cache if enabled.
+// cached bars are shared, do not modify them
+func (h *History) ReadBars(symbol string) (Bars, error) {
+	h.RLock()
+	cache := h.cache
+	h.RUnlock()
+	if cache == nil {
+		return ReadBars(symbol)
+	}
+
+	key := strings.ToLower(symbol)
+	if bars, ok := cache.get(key); ok {
+		return bars, nil
+	}
+	gen := cache.generation(key)
+	bars, err := ReadBars(symbol)
+	if err != nil {
+		return bars, err
+	}
+	cache.put(key, bars, gen)
+	return bars, nil
+}
+
+// invalidate cached bars of symbol after its file is written, call with lock held
+func (h *History) invalidate(symbol string) {
+	if h.cache != nil {
+		h.cache.remove(strings.ToLower(symbol))
+	}
+}
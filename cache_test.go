@@ -0,0 +1,52 @@
+package history
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+func TestReadBarsCache(t *testing.T) {
+	h := newTestHistory(t)
+	h.SetCache(2)
+	bars := makeBars(10, time.Hour)
+	if err := WriteBars("BTCUSDT1h", bars[1:]); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := h.ReadBars("BTCUSDT1h"); err != nil {
+		t.Fatal(err)
+	}
+
+	// second read hits the cache, even with the file gone
+	file := filepath.Join(dataDir(), "btcusdt1h.json")
+	if err := os.Rename(file, file+".bak"); err != nil {
+		t.Fatal(err)
+	}
+	cached, err := h.ReadBars("BTCUSDT1h")
+	if err != nil || len(cached) != 9 {
+		t.Fatalf("cached read got %d bars (%v), want 9", len(cached), err)
+	}
+	if err := os.Rename(file+".bak", file); err != nil {
+		t.Fatal(err)
+	}
+
+	// Add writes the file and invalidates the cache
+	h.Add("BTCUSDT1h", bars[1:])
+	h.Add("BTCUSDT1h", bars[:1])
+	read, err := h.ReadBars("BTCUSDT1h")
+	if err != nil || len(read) != 10 {
+		t.Fatalf("read after Add got %d bars (%v), want 10", len(read), err)
+	}
+}
+
+func TestReadBarsCacheDropsStaleRead(t *testing.T) {
+	c := newBarCache(2)
+	gen := c.generation("btcusdt1h")
+	// invalidated while the read was in flight
+	c.remove("btcusdt1h")
+	c.put("btcusdt1h", makeBars(3, time.Hour), gen)
+	if _, ok := c.get("btcusdt1h"); ok {
+		t.Fatal("stale read was cached")
+	}
+}
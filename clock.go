@@ -0,0 +1,67 @@
+package history
+
+import (
+	"sync"
+	"time"
+)
+
+// Clock interface for reading current time
+type Clock interface {
+	Now() time.Time
+}
+
+// realClock reads system time
+type realClock struct{}
+
+func (realClock) Now() time.Time {
+	return time.Now()
+}
+
+// FakeClock only moves when set, for reproducible tests
+type FakeClock struct {
+	t time.Time
+	sync.Mutex
+}
+
+// NewFakeClock returns a FakeClock starting at t
+func NewFakeClock(t time.Time) *FakeClock {
+	return &FakeClock{t: t}
+}
+
+// Now returns fake time
+func (c *FakeClock) Now() time.Time {
+	c.Lock()
+	defer c.Unlock()
+
+	return c.t
+}
+
+// Set fake time
+func (c *FakeClock) Set(t time.Time) {
+	c.Lock()
+	c.t = t
+	c.Unlock()
+}
+
+// Add moves fake time forward
+func (c *FakeClock) Add(d time.Duration) {
+	c.Lock()
+	c.t = c.t.Add(d)
+	c.Unlock()
+}
+
+// SetClock changes the clock used for time reads, nil restores real time.
+// Test, TestSymbol and PortfolioTest move a FakeClock to the time of every bar
+func (h *History) SetClock(c Clock) {
+	h.Lock()
+	h.clock = c
+	h.Unlock()
+}
+
+// now returns current time of history clock
+func (h *History) now() time.Time {
+	if h.clock == nil {
+		return realClock{}.Now()
+	}
+	return h.clock.Now()
+}
@@ -0,0 +1,70 @@
+package history
+
+import (
+	"reflect"
+	"testing"
+	"time"
+)
+
+// brokerStrategy buys and closes on every fifth bar through a PaperBroker,
+// leaving event time to the tester and fill time to the clock
+type brokerStrategy struct {
+	broker *PaperBroker
+	fills  []Fill
+}
+
+func (s *brokerStrategy) Run(symbol string, bars Bars) (Event, bool) {
+	if len(bars)%5 != 0 {
+		return Event{}, false
+	}
+	event := NewEvent(symbol)
+	event.Type = MARKET_BUY
+	if len(bars)%10 == 0 {
+		event.Type = CLOSE_BUY
+	}
+	event.Price = bars[0].Close
+	event.Size = 1
+
+	fill, err := s.broker.PlaceOrder(event)
+	if err != nil || s.broker.Portfolio.AddFill(fill) != nil {
+		return Event{}, false
+	}
+	s.fills = append(s.fills, fill)
+	return event, true
+}
+
+func TestFakeClockBacktestRepeats(t *testing.T) {
+	h := newTestHistory(t)
+	h.SetPersist(false)
+	h.Add("BTCUSDT1h", makeBars(30, time.Hour))
+	clock := NewFakeClock(time.Date(2030, 1, 1, 0, 0, 0, 0, time.UTC))
+	h.SetClock(clock)
+
+	run := func() (*TestResult, []Fill) {
+		broker := NewPaperBroker(1000, 0.1)
+		broker.Clock = clock
+		s := &brokerStrategy{broker: broker}
+		result, err := h.TestSymbol(s, "BTCUSDT1h", h.FirstTime(), h.LastTime())
+		if err != nil {
+			t.Fatal(err)
+		}
+		return result, s.fills
+	}
+
+	first, fills := run()
+	second, again := run()
+	if !reflect.DeepEqual(first, second) || !reflect.DeepEqual(fills, again) {
+		t.Fatal("repeated backtest differs")
+	}
+	if len(fills) != 6 || len(first.Events) != 6 {
+		t.Fatalf("got %d fills and %d events, want 6", len(fills), len(first.Events))
+	}
+	for i, fill := range fills {
+		if !fill.Time.Equal(first.Events[i].Time) {
+			t.Fatalf("fill %d at %v, event at %v", i, fill.Time, first.Events[i].Time)
+		}
+	}
+	if !clock.Now().Equal(h.LastTime()) {
+		t.Fatalf("clock at %v, want last bar %v", clock.Now(), h.LastTime())
+	}
+}
@@ -0,0 +1,111 @@
+package history
+
+import (
+	"encoding/csv"
+	"fmt"
+	"os"
+	"strconv"
+	"time"
+)
+
+// WriteCSV saves bars to a csv file, oldest bar first
+// with columns Time (RFC3339), Open, High, Low, Close, Volume, QuoteVolume, Trades
+func (bars Bars) WriteCSV(filename string) error {
+	f, err := os.Create(filename)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+
+	w := csv.NewWriter(f)
+	if err := w.Write([]string{"Time", "Open", "High", "Low", "Close", "Volume", "QuoteVolume", "Trades"}); err != nil {
+		return err
+	}
+	for i := len(bars) - 1; i >= 0; i-- {
+		b := bars[i]
+		record := []string{
+			b.Time.Format(time.RFC3339),
+			strconv.FormatFloat(b.Open, 'f', -1, 64),
+			strconv.FormatFloat(b.High, 'f', -1, 64),
+			strconv.FormatFloat(b.Low, 'f', -1, 64),
+			strconv.FormatFloat(b.Close, 'f', -1, 64),
+			strconv.FormatFloat(b.Volume, 'f', -1, 64),
+			strconv.FormatFloat(b.QuoteVolume, 'f', -1, 64),
+			strconv.FormatInt(b.Trades, 10),
+		}
+		if err := w.Write(record); err != nil {
+			return err
+		}
+	}
+	w.Flush()
+
+	return w.Error()
+}
+
+// ReadCSV loads bars from a csv file with columns Time, Open, High, Low, Close
+// and optional Volume, QuoteVolume and Trades. Time can be RFC3339 or unix seconds,
+// a first row with a non numeric Open column is a header and is skipped
+func ReadCSV(filename string) (Bars, error) {
+	f, err := os.Open(filename)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+
+	r := csv.NewReader(f)
+	r.FieldsPerRecord = -1
+	r.TrimLeadingSpace = true
+
+	records, err := r.ReadAll()
+	if err != nil {
+		return nil, err
+	}
+
+	var bars = make(Bars, 0, len(records))
+	for i, record := range records {
+		if len(record) < 5 {
+			return nil, fmt.Errorf("line %d: expected at least 5 columns, got %d", i+1, len(record))
+		}
+		// skip header, whatever its column names
+		if i == 0 && !isNumber(record[1]) {
+			continue
+		}
+
+		var bar Bar
+		if bar.Time, err = parseCSVTime(record[0]); err != nil {
+			return nil, fmt.Errorf("line %d: %v", i+1, err)
+		}
+		fields := []*float64{&bar.Open, &bar.High, &bar.Low, &bar.Close, &bar.Volume, &bar.QuoteVolume}
+		for n, field := range fields {
+			if n+1 >= len(record) {
+				break
+			}
+			if *field, err = strconv.ParseFloat(record[n+1], 64); err != nil {
+				return nil, fmt.Errorf("line %d: %v", i+1, err)
+			}
+		}
+		if len(record) > 7 {
+			if bar.Trades, err = strconv.ParseInt(record[7], 10, 64); err != nil {
+				return nil, fmt.Errorf("line %d: %v", i+1, err)
+			}
+		}
+		bars = append(bars, bar)
+	}
+
+	return bars.Sort(), nil
+}
+
+// isNumber reports if s is a float
+func isNumber(s string) bool {
+	_, err := strconv.ParseFloat(s, 64)
+	return err == nil
+}
+
+// parseCSVTime parses RFC3339 or unix seconds
+func parseCSVTime(s string) (time.Time, error) {
+	if ts, err := strconv.ParseInt(s, 10, 64); err == nil {
+		return time.Unix(ts, 0).UTC(), nil
+	}
+	t, err := time.Parse(time.RFC3339, s)
+	return t.UTC(), err
+}
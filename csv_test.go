@@ -0,0 +1,72 @@
+package history
+
+import (
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+	"time"
+)
+
+// withQuote returns bars with quote volume and trades set
+func withQuote(bars Bars) Bars {
+	for i := range bars {
+		bars[i].QuoteVolume = bars[i].Volume * bars[i].Close
+		bars[i].Trades = int64(i + 1)
+	}
+	return bars
+}
+
+func TestCSVRoundTrip(t *testing.T) {
+	bars := withQuote(makeBars(10, time.Hour))
+	filename := filepath.Join(t.TempDir(), "bars.csv")
+	if err := bars.WriteCSV(filename); err != nil {
+		t.Fatal(err)
+	}
+	got, err := ReadCSV(filename)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !reflect.DeepEqual(got, bars) {
+		t.Fatalf("got %v, want %v", got, bars)
+	}
+}
+
+func TestReadCSVFormats(t *testing.T) {
+	dir := t.TempDir()
+	write := func(name, data string) string {
+		filename := filepath.Join(dir, name)
+		if err := os.WriteFile(filename, []byte(data), 0644); err != nil {
+			t.Fatal(err)
+		}
+		return filename
+	}
+
+	// unix seconds without header and volume, any order
+	bars, err := ReadCSV(write("unix.csv", "1704070800,2,3,1,2.5\n1704067200,1,2,0.5,1.5\n"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(bars) != 2 || !bars[0].Time.Equal(start.Add(time.Hour)) || bars[0].Close != 2.5 || bars[1].Volume != 0 {
+		t.Fatalf("got %v", bars)
+	}
+	if bars[0].Time.Location() != time.UTC {
+		t.Fatalf("time %v not in UTC", bars[0].Time)
+	}
+
+	if _, err := ReadCSV(write("short.csv", "Time,Open,High,Low,Close\n1704067200,1,2,0.5\n")); err == nil {
+		t.Fatal("want error for missing close")
+	}
+	// any header names
+	bars, err = ReadCSV(write("header.csv", "open_time,o,h,l,c\n2024-01-01T00:00:00Z,1,2,0.5,1.5\n"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(bars) != 1 || !bars[0].Time.Equal(start) || bars[0].Close != 1.5 {
+		t.Fatalf("got %v", bars)
+	}
+
+	if _, err := ReadCSV(write("bad.csv", "2024-01-01,1,2,0.5,1.5\n")); err == nil {
+		t.Fatal("want error for bad time")
+	}
+}
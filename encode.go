@@ -0,0 +1,100 @@
+package history
+
+import (
+	"encoding/base64"
+	"encoding/binary"
+	"errors"
+	"math"
+	"time"
+)
+
+/*
+	Encode layout, all little-endian and oldest bar first:
+
+	uint64    number of bars n
+	int64[n]  times in unix seconds
+	float64[n] open, high, low, close, volume and quote volume, one column each
+	int64[n]  trades
+
+	every column starts at a multiple of 8 bytes, so in the browser
+	new BigInt64Array(buf, 8, n) and new Float64Array(buf, 8+8*n*k, n) read them directly,
+	trades are at new BigInt64Array(buf, 8+8*n*7, n)
+*/
+
+// encodeColumns is the number of float64 columns in Encode
+const encodeColumns = 6
+
+// Encode returns bars in a compact binary layout for transfer
+func (bars Bars) Encode() []byte {
+	n := len(bars)
+	buf := make([]byte, 8+8*n*(2+encodeColumns))
+	binary.LittleEndian.PutUint64(buf, uint64(n))
+
+	times, open, high, low, close, volume := bars.Columns()
+	quoteVolume, trades := bars.ExtraColumns()
+	off := 8
+	for _, t := range times {
+		binary.LittleEndian.PutUint64(buf[off:], uint64(t))
+		off += 8
+	}
+	for _, column := range [][]float64{open, high, low, close, volume, quoteVolume} {
+		for _, v := range column {
+			binary.LittleEndian.PutUint64(buf[off:], math.Float64bits(v))
+			off += 8
+		}
+	}
+	for _, v := range trades {
+		binary.LittleEndian.PutUint64(buf[off:], uint64(v))
+		off += 8
+	}
+
+	return buf
+}
+
+// DecodeBars returns bars from data made by Encode
+func DecodeBars(data []byte) (Bars, error) {
+	if len(data) < 8 {
+		return nil, errors.New("data too short")
+	}
+	n := binary.LittleEndian.Uint64(data)
+	if n > uint64(len(data)) || uint64(len(data)) != 8+8*n*(2+encodeColumns) {
+		return nil, errors.New("invalid data length")
+	}
+
+	column := func(k, i int) float64 {
+		return math.Float64frombits(binary.LittleEndian.Uint64(data[8+8*int(n)*(1+k)+8*i:]))
+	}
+
+	bars := make(Bars, n)
+	for i := 0; i < int(n); i++ {
+		t := int64(binary.LittleEndian.Uint64(data[8+8*i:]))
+		// newest first
+		bars[int(n)-1-i] = Bar{
+			Time:        time.Unix(t, 0).UTC(),
+			Open:        column(0, i),
+			High:        column(1, i),
+			Low:         column(2, i),
+			Close:       column(3, i),
+			Volume:      column(4, i),
+			QuoteVolume: column(5, i),
+			Trades:      int64(binary.LittleEndian.Uint64(data[8+8*int(n)*(1+encodeColumns)+8*i:])),
+		}
+	}
+
+	return bars, nil
+}
+
+// EncodeBase64 returns Encode as a base64 string, decode it in the browser with
+// Uint8Array.from(atob(s), c => c.charCodeAt(0)).buffer
+func (bars Bars) EncodeBase64() string {
+	return base64.StdEncoding.EncodeToString(bars.Encode())
+}
+
+// DecodeBarsBase64 returns bars from a string made by EncodeBase64
+func DecodeBarsBase64(s string) (Bars, error) {
+	data, err := base64.StdEncoding.DecodeString(s)
+	if err != nil {
+		return nil, err
+	}
+	return DecodeBars(data)
+}
@@ -0,0 +1,32 @@
+package history
+
+import (
+	"reflect"
+	"testing"
+	"time"
+)
+
+func TestEncodeRoundTrip(t *testing.T) {
+	bars := withQuote(makeBars(10, time.Hour))
+	got, err := DecodeBarsBase64(bars.EncodeBase64())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !reflect.DeepEqual(got, bars) {
+		t.Fatalf("got %v, want %v", got, bars)
+	}
+}
+
+func TestColumns(t *testing.T) {
+	bars := withQuote(makeBars(3, time.Hour))
+	times, open, _, _, close, volume := bars.Columns()
+	quoteVolume, trades := bars.ExtraColumns()
+
+	// oldest first
+	if times[0] != start.Unix() || open[0] != 100 || close[2] != 102 || volume[1] != 10 {
+		t.Fatalf("columns %v %v %v %v", times, open, close, volume)
+	}
+	if quoteVolume[2] != bars[0].QuoteVolume || trades[0] != bars[2].Trades {
+		t.Fatalf("extra columns %v %v", quoteVolume, trades)
+	}
+}
@@ -1,6 +1,8 @@
 package history
 
 import (
+	"encoding/json"
+	"reflect"
 	"sort"
 	"time"
 )
@@ -10,6 +12,34 @@ type Strategy interface {
 	Run(string, Bars) (Event, bool)
 }
 
+// WarmUpStrategy is a Strategy that needs MinBars bars before it runs.
+// tests and the event listener skip Run on shorter bars, strategies
+// without MinBars run from the first bar
+type WarmUpStrategy interface {
+	Strategy
+	MinBars() int
+}
+
+// minBars returns bars strategy needs before it runs, 0 if not a WarmUpStrategy
+func minBars(strategy Strategy) int {
+	if s, ok := strategy.(WarmUpStrategy); ok {
+		return s.MinBars()
+	}
+	return 0
+}
+
+// strategyName returns the type name of strategy without package and pointer
+func strategyName(strategy interface{}) string {
+	t := reflect.TypeOf(strategy)
+	for t != nil && t.Kind() == reflect.Ptr {
+		t = t.Elem()
+	}
+	if t == nil {
+		return ""
+	}
+	return t.Name()
+}
+
 // Event data for specific time and price
 type Event struct {
 	Symbol    string
@@ -21,6 +51,79 @@ type Event struct {
 	Time      time.Time
 	Price     float64
 	Size      float64
+	// Strategy that emitted the event
+	Strategy string
+	// Stop and Target exit prices of bracket orders, 0 if not set
+	Stop   float64
+	Target float64
+	// Meta holds indicator values at the time of the event
+	Meta map[string]float64
+}
+
+func (event Event) MarshalJSON() ([]byte, error) {
+	m := map[string]interface{}{
+		"Symbol":    event.Symbol,
+		"Pair":      event.Pair,
+		"Timeframe": event.Timeframe,
+		"Name":      event.Name,
+		"Text":      event.Text,
+		"Type":      event.Type,
+		"Time":      event.Time.Unix(),
+		"Price":     event.Price,
+		"Size":      event.Size,
+	}
+	if event.Strategy != "" {
+		m["Strategy"] = event.Strategy
+	}
+	if event.Stop != 0 {
+		m["Stop"] = event.Stop
+	}
+	if event.Target != 0 {
+		m["Target"] = event.Target
+	}
+	if len(event.Meta) > 0 {
+		m["Meta"] = event.Meta
+	}
+
+	return json.Marshal(m)
+}
+
+func (event *Event) UnmarshalJSON(data []byte) error {
+	var v struct {
+		Symbol    string
+		Pair      string
+		Timeframe string
+		Name      string
+		Text      string
+		Type      EventType
+		Time      int64
+		Price     float64
+		Size      float64
+		Strategy  string
+		Stop      float64
+		Target    float64
+		Meta      map[string]float64
+	}
+	if err := json.Unmarshal(data, &v); err != nil {
+		return err
+	}
+
+	*event = Event{
+		Symbol:    v.Symbol,
+		Pair:      v.Pair,
+		Timeframe: v.Timeframe,
+		Name:      v.Name,
+		Text:      v.Text,
+		Type:      v.Type,
+		Time:      time.Unix(v.Time, 0).UTC(),
+		Price:     v.Price,
+		Size:      v.Size,
+		Strategy:  v.Strategy,
+		Stop:      v.Stop,
+		Target:    v.Target,
+		Meta:      v.Meta,
+	}
+	return nil
 }
 
 // EventType
@@ -38,6 +141,8 @@ const (
 	MODIFY
 	NEWS
 	OTHER
+
+	FORECAST
 )
 
 // EventTypes
@@ -51,6 +156,7 @@ var EventTypes = map[EventType]string{
 	MODIFY:      "MODIFY",
 	NEWS:        "NEWS",
 	OTHER:       "OTHER",
+	FORECAST:    "FORECAST",
 }
 
 // NewEvent
@@ -59,6 +165,25 @@ func NewEvent(symbol string) Event {
 	return Event{Symbol: symbol, Pair: pair, Timeframe: tf}
 }
 
+// BuyBracket returns a MARKET_BUY event with stop and target exits attached
+func BuyBracket(symbol string, t time.Time, size, entry, stop, target float64) Event {
+	event := NewEvent(symbol)
+	event.Type = MARKET_BUY
+	event.Time = t
+	event.Size = size
+	event.Price = entry
+	event.Stop = stop
+	event.Target = target
+	return event
+}
+
+// SellBracket returns a MARKET_SELL event with stop and target exits attached
+func SellBracket(symbol string, t time.Time, size, entry, stop, target float64) Event {
+	event := BuyBracket(symbol, t, size, entry, stop, target)
+	event.Type = MARKET_SELL
+	return event
+}
+
 // Returns true if event is of any type buy
 func (event *Event) IsBuy() bool {
 	if event.Type == MARKET_BUY || event.Type == LIMIT_BUY || event.Type == CLOSE_BUY {
@@ -89,6 +214,17 @@ func (events Events) Sort() Events {
 	return events
 }
 
+// SortByTime sorts events oldest first, events at the same time by symbol
+func (events Events) SortByTime() Events {
+	sort.SliceStable(events, func(i, j int) bool {
+		if events[i].Time.Equal(events[j].Time) {
+			return events[i].Symbol < events[j].Symbol
+		}
+		return events[i].Time.Before(events[j].Time)
+	})
+	return events
+}
+
 // Return events for given symbol
 func (events Events) Symbol(symbol string) Events {
 	var ev Events
@@ -100,6 +236,31 @@ func (events Events) Symbol(symbol string) Events {
 	return ev
 }
 
+// ByType returns events of any of the given types
+func (events Events) ByType(types ...EventType) Events {
+	var ev Events
+	for _, event := range events {
+		for _, t := range types {
+			if event.Type == t {
+				ev = append(ev, event)
+				break
+			}
+		}
+	}
+	return ev
+}
+
+// Between returns events from start to end time (inclusive)
+func (events Events) Between(start, end time.Time) Events {
+	var ev Events
+	for _, event := range events {
+		if !event.Time.Before(start) && !event.Time.After(end) {
+			ev = append(ev, event)
+		}
+	}
+	return ev
+}
+
 // Exists
 func (events Events) Exists(event Event) bool {
 	for _, old := range events {
@@ -167,7 +328,7 @@ func (events *Events) Add(event Event) bool {
 // Delete event from events list
 func (events *Events) Del(event Event) bool {
 	for i, v := range *events {
-		if v == event {
+		if v.Symbol == event.Symbol && v.Type == event.Type && v.Time == event.Time && v.Price == event.Price {
 			*events = append((*events)[:i], (*events)[i+1:]...)
 			return true
 		}
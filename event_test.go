@@ -0,0 +1,30 @@
+package history
+
+import (
+	"path/filepath"
+	"reflect"
+	"testing"
+)
+
+func TestEventMeta(t *testing.T) {
+	filename := filepath.Join(t.TempDir(), "meta.json")
+	event := BuyBracket("BTCUSDT1h", start, 1, 100, 90, 120)
+	event.Meta = map[string]float64{"rsi": 28.5, "atr": 1.2}
+	events := Events{event}
+
+	if err := events.WriteJSON(filename); err != nil {
+		t.Fatal(err)
+	}
+	got, err := ReadEventsJSON(filename)
+	if err != nil || len(got) != 1 {
+		t.Fatalf("read %d events (%v), want 1", len(got), err)
+	}
+	if !reflect.DeepEqual(got[0].Meta, event.Meta) {
+		t.Fatalf("meta %v, want %v", got[0].Meta, event.Meta)
+	}
+
+	// events with meta can not be compared with ==
+	if !events.Del(got[0]) || len(events) != 0 {
+		t.Fatal("event with meta not deleted")
+	}
+}
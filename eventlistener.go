@@ -2,99 +2,203 @@ package history
 
 import (
 	"errors"
-	"fmt"
 	"log"
+	"sync"
 )
 
 // EventListener is where you subscribe strategies too
 type EventListener struct {
 	strategies []Strategy
-	running    bool
+	// scopes limits strategies (by name) to symbols, pairs or timeframes
+	scopes  map[string][]string
+	running bool
+	broker  Broker
+	// portfolio gets fills of broker
+	portfolio *Portfolio
+	done      chan struct{}
+	stopped   chan struct{}
+
+	mu sync.Mutex
 }
 
 // Start event listener
 func (e *EventListener) Start(hist *History, events *Events) error {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+
 	if e.running {
 		return errors.New("alredy running")
 	}
 	e.running = true
+	e.done = make(chan struct{})
+	e.stopped = make(chan struct{})
 	log.Println("[EVENTLISTENER] started")
 
-	go func() {
-		for {
+	updates := hist.Subscribe()
+	go func(done, stopped chan struct{}) {
+		defer close(stopped)
 
+		for {
 			select {
-			case symbol := <-hist.C:
-				if len(e.strategies) == 0 {
+			case u := <-updates:
+				e.run(hist, u.Symbol, events)
+
+			case <-done:
+				// process updates already queued
+				hist.drain(updates, func(u BarUpdate) {
+					e.run(hist, u.Symbol, events)
+				})
+				log.Println("[EVENTLISTENER] stopped")
+				return
+			}
+		}
+	}(e.done, e.stopped)
+	return nil
+}
+
+// run all strategies on bars of symbol
+func (e *EventListener) run(hist *History, symbol string, events *Events) {
+	e.mu.Lock()
+	broker, portfolio := e.broker, e.portfolio
+	strategies := make([]Strategy, 0, len(e.strategies))
+	for _, strategy := range e.strategies {
+		if e.inScope(strategy, symbol) {
+			strategies = append(strategies, strategy)
+		}
+	}
+	e.mu.Unlock()
+	if len(strategies) == 0 {
+		return
+	}
+	bars := hist.Bars(symbol)
+	for _, strategy := range strategies {
+		if len(bars) < minBars(strategy) {
+			continue
+		}
+		if event, ok := strategy.Run(symbol, bars); ok {
+			if event.Strategy == "" {
+				event.Strategy = strategyName(strategy)
+			}
+
+			ok := events.Add(event)
+			if !ok {
+				continue
+			}
+			// preform action
+			log.Printf("%s %s %s %s %.8f\n", event.Symbol, EventTypes[event.Type], event.Name, event.Text, event.Price)
+			if broker == nil {
+				continue
+			}
+			switch event.Type {
+			case MARKET_BUY, MARKET_SELL, CLOSE_BUY, CLOSE_SELL:
+				fill, err := broker.PlaceOrder(event)
+				if err != nil {
+					log.Printf("[EVENTLISTENER] %s order failed: %v\n", event.Symbol, err)
 					continue
 				}
-				// run all strategies on bars
-				bars := hist.Bars(symbol)
-				for _, strategy := range e.strategies {
-					if event, ok := strategy.Run(symbol, bars); ok {
-
-						ok := events.Add(event)
-						if !ok {
-							continue
-						}
-						// preform action
-						log.Printf("%s %s %s %s %.8f\n", event.Symbol, EventTypes[event.Type], event.Name, event.Text, event.Price)
-					}
+				log.Printf("[EVENTLISTENER] %s filled %s %.8f @%.8f fee=%.8f\n", fill.Symbol, EventTypes[fill.Type], fill.Size, fill.Price, fill.Fee)
+				if portfolio == nil {
+					continue
 				}
-
-			default:
-				if !e.running {
-					log.Println("[EVENTLISTENER] stopped")
-					return
+				if err := portfolio.AddFill(fill); err != nil {
+					log.Printf("[EVENTLISTENER] %s fill not added: %v\n", fill.Symbol, err)
 				}
 			}
+		}
+	}
+}
+
+// SetBroker places orders of market and close events and adds their fills to portfolio p,
+// nil only runs strategies. with a PaperBroker pass its Portfolio
+func (e *EventListener) SetBroker(b Broker, p *Portfolio) {
+	e.mu.Lock()
+	e.broker = b
+	e.portfolio = p
+	e.mu.Unlock()
+}
 
+// inScope returns true if strategy runs on symbol
+func (e *EventListener) inScope(strategy Strategy, symbol string) bool {
+	scope, ok := e.scopes[strategyName(strategy)]
+	if !ok {
+		return true
+	}
+	pair, tf := SplitSymbol(symbol)
+	for _, s := range scope {
+		if s == symbol || s == pair || s == tf {
+			return true
 		}
-	}()
-	return nil
+	}
+	return false
 }
 
 // List added strategies
 func (e *EventListener) List() {
 	for _, strategy := range e.strategies {
-		_name := fmt.Sprintf("%T", strategy)[6:]
+		_name := strategyName(strategy)
 		log.Println("[EVENTLISTENER]", _name)
 	}
 }
 
-// Stop event listener
+// Stop event listener, waits until queued signals are processed
 func (e *EventListener) Stop() error {
+	e.mu.Lock()
 	if !e.running {
+		e.mu.Unlock()
 		return errors.New("not running")
 	}
 	e.running = false
+	close(e.done)
+	stopped := e.stopped
+	e.mu.Unlock()
+
+	<-stopped
 	return nil
 }
 
 // Add strategy
 func (e *EventListener) Add(strategy Strategy) error {
-	name := fmt.Sprintf("%T", strategy)[6:]
+	return e.AddFor(strategy)
+}
+
+// AddFor adds strategy that only runs on given symbols, pairs or timeframes,
+// no symbols runs on all
+func (e *EventListener) AddFor(strategy Strategy, symbols ...string) error {
+	name := strategyName(strategy)
+	e.mu.Lock()
+	defer e.mu.Unlock()
 
 	for _, _strategy := range e.strategies {
-		_name := fmt.Sprintf("%T", _strategy)[6:]
+		_name := strategyName(_strategy)
 		if name == _name {
 			return errors.New("alredy exist")
 		}
 	}
 	e.strategies = append(e.strategies, strategy)
+	if len(symbols) > 0 {
+		if e.scopes == nil {
+			e.scopes = make(map[string][]string)
+		}
+		e.scopes[name] = symbols
+		log.Println("[EVENTLISTENER] added", name, "for", symbols)
+		return nil
+	}
 	log.Println("[EVENTLISTENER] added", name)
 	return nil
 }
 
 // Remove strategy
 func (e *EventListener) Remove(strategy Strategy) error {
-	name := fmt.Sprintf("%T", strategy)[6:]
+	name := strategyName(strategy)
+	e.mu.Lock()
+	defer e.mu.Unlock()
 
 	for i, _strategy := range e.strategies {
 		if _strategy == strategy {
 			l := len(e.strategies) - 1
 			e.strategies[i] = e.strategies[l]
 			e.strategies = e.strategies[:l]
+			delete(e.scopes, name)
 
 			log.Println("[EVENTLISTENER] removed", name)
 			return nil
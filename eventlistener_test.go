@@ -0,0 +1,52 @@
+package history
+
+import (
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+// slowCounter counts runs and takes a while on each
+type slowCounter struct {
+	runs int64
+}
+
+func (s *slowCounter) Run(symbol string, bars Bars) (Event, bool) {
+	time.Sleep(time.Millisecond)
+	atomic.AddInt64(&s.runs, 1)
+	return Event{}, false
+}
+
+func TestListenerStopDrainsUpdates(t *testing.T) {
+	h := newTestHistory(t)
+	h.SetPersist(false)
+	s := &slowCounter{}
+	var e EventListener
+	e.Add(s)
+
+	var events Events
+	if err := e.Start(h, &events); err != nil {
+		t.Fatal(err)
+	}
+	if err := e.Start(h, &events); err == nil {
+		t.Fatal("want error starting twice")
+	}
+
+	const adds = 20
+	bars := makeBars(adds+5, time.Hour)
+	h.Add("BTCUSDT1h", bars[adds:])
+	for i := adds - 1; i >= 0; i-- {
+		h.Add("BTCUSDT1h", bars[i:i+1])
+	}
+	if err := e.Stop(); err != nil {
+		t.Fatal(err)
+	}
+
+	// every update queued before Stop is processed before it returns
+	if runs := atomic.LoadInt64(&s.runs); runs != adds+1 {
+		t.Fatalf("strategy ran %d times, want %d", runs, adds+1)
+	}
+	if err := e.Stop(); err == nil {
+		t.Fatal("want error stopping twice")
+	}
+}
@@ -1,23 +1,55 @@
 package main
 
 import (
+	"context"
 	"encoding/json"
 	"fmt"
 	"io"
 	"log"
+	"math"
 	"net/http"
 	"strconv"
 	"strings"
 	"time"
 
+	"github.com/gorilla/websocket"
 	"github.com/slicken/history"
+	"golang.org/x/time/rate"
 )
 
 // Binance data loaders
-type Binance struct{}
+type Binance struct {
+	limiter *rate.Limiter
+}
+
+// NewBinance returns a Binance downloader limited to rps request weight per second.
+// Binance allows 1200 weight per minute (20/s)
+func NewBinance(rps float64) *Binance {
+	return &Binance{limiter: rate.NewLimiter(rate.Limit(rps), int(math.Max(rps, 10)))}
+}
+
+// klinesWeight returns Binance request weight of klines for limit
+func klinesWeight(limit int) int {
+	switch {
+	case limit < 100:
+		return 1
+	case limit < 500:
+		return 2
+	case limit <= 1000:
+		return 5
+	default:
+		return 10
+	}
+}
 
 // GetKlines new data from Binance exchange
 func (e Binance) GetKlines(pair, timeframe string, limit int) (history.Bars, error) {
+	if e.limiter != nil {
+		if err := e.limiter.WaitN(context.Background(), klinesWeight(limit)); err != nil {
+			return nil, err
+		}
+	}
+
 	path := fmt.Sprintf(
 		"https://api.binance.com/api/v1/klines?symbol=%s&interval=%s&limit=%v",
 		strings.ToUpper(pair), strings.ToLower(timeframe), limit)
@@ -42,7 +74,7 @@ func (e Binance) GetKlines(pair, timeframe string, limit int) (history.Bars, err
 	for i, v := range raw {
 		bar := history.Bar{}
 
-		bar.Time = time.Unix(int64(v[0].(float64))/1000, 0) // .UTC()
+		bar.Time = time.Unix(int64(v[0].(float64))/1000, 0).UTC()
 		bar.Open, err = strconv.ParseFloat(v[1].(string), 64)
 		if err != nil {
 			log.Printf("error bars[%d].Open\n", i)
@@ -63,6 +95,15 @@ func (e Binance) GetKlines(pair, timeframe string, limit int) (history.Bars, err
 		if err != nil {
 			log.Printf("error bars[%d].Volume\n", i)
 		}
+		if len(v) > 8 {
+			bar.QuoteVolume, err = strconv.ParseFloat(v[7].(string), 64)
+			if err != nil {
+				log.Printf("error bars[%d].QuoteVolume\n", i)
+			}
+			if trades, ok := v[8].(float64); ok {
+				bar.Trades = int64(trades)
+			}
+		}
 		// insert
 		bars = append(history.Bars{bar}, bars...)
 	}
@@ -70,6 +111,74 @@ func (e Binance) GetKlines(pair, timeframe string, limit int) (history.Bars, err
 	return bars, nil
 }
 
+// binanceKline websocket message
+type binanceKline struct {
+	K struct {
+		Time        int64  `json:"t"`
+		Open        string `json:"o"`
+		High        string `json:"h"`
+		Low         string `json:"l"`
+		Close       string `json:"c"`
+		Volume      string `json:"v"`
+		QuoteVolume string `json:"q"`
+		Trades      int64  `json:"n"`
+		Closed      bool   `json:"x"`
+	} `json:"k"`
+}
+
+// StreamKlines sends closed bars from Binance websocket, history uses it instead of polling
+func (e Binance) StreamKlines(ctx context.Context, pair, timeframe string) (<-chan history.Bar, error) {
+	path := fmt.Sprintf("wss://stream.binance.com:9443/ws/%s@kline_%s",
+		strings.ToLower(pair), strings.ToLower(timeframe))
+
+	conn, _, err := websocket.DefaultDialer.DialContext(ctx, path, nil)
+	if err != nil {
+		return nil, err
+	}
+
+	// close connection when ctx is done to unblock ReadJSON
+	go func() {
+		<-ctx.Done()
+		conn.Close()
+	}()
+
+	c := make(chan history.Bar)
+	go func() {
+		defer close(c)
+		defer conn.Close()
+
+		for {
+			var msg binanceKline
+			if err := conn.ReadJSON(&msg); err != nil {
+				if ctx.Err() == nil {
+					log.Printf("%s%s stream: %v\n", pair, timeframe, err)
+				}
+				return
+			}
+			// only send closed bars
+			if !msg.K.Closed {
+				continue
+			}
+
+			bar := history.Bar{Time: time.UnixMilli(msg.K.Time).UTC(), Trades: msg.K.Trades}
+			bar.Open, _ = strconv.ParseFloat(msg.K.Open, 64)
+			bar.High, _ = strconv.ParseFloat(msg.K.High, 64)
+			bar.Low, _ = strconv.ParseFloat(msg.K.Low, 64)
+			bar.Close, _ = strconv.ParseFloat(msg.K.Close, 64)
+			bar.Volume, _ = strconv.ParseFloat(msg.K.Volume, 64)
+			bar.QuoteVolume, _ = strconv.ParseFloat(msg.K.QuoteVolume, 64)
+
+			select {
+			case c <- bar:
+			case <-ctx.Done():
+				return
+			}
+		}
+	}()
+
+	return c, nil
+}
+
 // MakeSymbolMultiTimeframe helper func for binance that makes slice of requested symbols and timeframes
 func MakeSymbolMultiTimeframe(currencie string, timeframes ...string) ([]string, error) {
 	// run func
@@ -20,6 +20,7 @@ var (
 	events        = new(history.Events)        // we store our events here, if we want to save them
 	strategy      = &test{}                    // engulfing strategy (create you owrn strategies)
 	chart         = highcharts.DefaultChart()  // we use highcharts for plotting
+	binance       = NewBinance(20)             // binance downloader limited to 20 weight/s
 
 	config = new(Config) // store argument configurations
 	// other
@@ -106,7 +107,7 @@ func main() {
 	// ----------------------------------------------------------------------------------------------
 	// add a downloader to the interface.
 	// ----------------------------------------------------------------------------------------------
-	hist.Downloader = &Binance{}
+	hist.Downloader = binance
 	// ----------------------------------------------------------------------------------------------
 	// change the default directory to store history data from exchange
 	// ----------------------------------------------------------------------------------------------
@@ -142,6 +143,7 @@ func main() {
 	http.HandleFunc("/", httpIndex)
 	http.HandleFunc("/test", httPortfolioTest)
 	http.HandleFunc("/backtest", httpBacktest)
+	http.HandleFunc("/report", httpReport)      // report of one symbol '?symbol=BTCUSDT1d'
 	http.HandleFunc("/top/", httpTopPreformers) // top preformers for x days
 	log.Fatal(http.ListenAndServe(":8080", nil))
 }
@@ -189,12 +191,12 @@ func httpBacktest(w http.ResponseWriter, r *http.Request) {
 		hist.Limit(config.limit)
 	}
 	// run strategy backtest on all data
-	ev, err := hist.PortfolioTest(strategy, hist.FirstTime(), hist.LastTime())
+	result, err := hist.PortfolioTest(strategy, hist.FirstTime(), hist.LastTime())
 	if err != nil {
 		log.Fatal(err)
 	}
 	// build charts
-	c, err := chart.BuildCharts(hist.Map(), ev.Map())
+	c, err := chart.BuildCharts(hist.Map(), result.Events.Map())
 	if err != nil {
 		http.Error(w, err.Error(), http.StatusInternalServerError)
 		return
@@ -203,6 +205,22 @@ func httpBacktest(w http.ResponseWriter, r *http.Request) {
 	w.Write(c)
 }
 
+// Report of strategy test on one symbol
+func httpReport(w http.ResponseWriter, r *http.Request) {
+	symbol := r.URL.Query().Get("symbol")
+	result, err := hist.TestSymbol(strategy, symbol, hist.FirstTime(), hist.LastTime())
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusNotFound)
+		return
+	}
+	c, err := chart.Report(result, hist.Bars(symbol))
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	w.Write(c)
+}
+
 // TopPreformers over 'url:8080/top/x' x = number of bars
 func httpTopPreformers(w http.ResponseWriter, r *http.Request) {
 	n := config.limit
@@ -214,7 +232,7 @@ func httpTopPreformers(w http.ResponseWriter, r *http.Request) {
 	}
 	// new copy of history, so we dont cut any bars from 'hist' struct
 	var copyHist = new(history.History)
-	copyHist.Downloader = &Binance{}
+	copyHist.Downloader = binance
 	copyHist.Update(false)
 	copyHist.Load(symbols...)
 	// limit history if 'http://127.0.0.1/top/N' is used
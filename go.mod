@@ -2,4 +2,19 @@ module github.com/slicken/history
 
 go 1.18
 
-require github.com/slicken/sentiment v0.0.0-20210718182008-d01a59368b45
+require (
+	github.com/gorilla/websocket v1.5.0
+	github.com/slicken/sentiment v0.0.0-20210718182008-d01a59368b45
+	github.com/xitongsys/parquet-go v1.6.2
+	github.com/xitongsys/parquet-go-source v0.0.0-20241021075129-b732d2ac9c9b
+	golang.org/x/time v0.5.0
+)
+
+require (
+	github.com/apache/arrow/go/arrow v0.0.0-20200730104253-651201b0f516 // indirect
+	github.com/apache/thrift v0.14.2 // indirect
+	github.com/golang/snappy v0.0.3 // indirect
+	github.com/klauspost/compress v1.15.9 // indirect
+	github.com/pierrec/lz4/v4 v4.1.8 // indirect
+	golang.org/x/xerrors v0.0.0-20200804184101-5ec99f83aff1 // indirect
+)
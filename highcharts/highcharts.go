@@ -6,11 +6,16 @@ import (
 	"fmt"
 	"log"
 	"math"
+	"net/http"
+	"os"
+	"sort"
+	"strings"
+	"time"
 
 	"github.com/slicken/history"
 )
 
-// MAXLIMIT on chart data arrays
+// MAXLIMIT on chart data arrays, charts downsample bars to it unless Chart.MaxPoints is set
 const MAXLIMIT = 10000
 
 // Chart holds chart settings
@@ -25,8 +30,66 @@ type Chart struct {
 	VolumeSMA int
 	// Shadows styles chart
 	Shadow bool
+	// Theme sets chart colors (Light|Dark)
+	Theme Theme
+	// TrendEMA colors bars by close above/below EMA period (0=off)
+	TrendEMA int
+	// Oscillator panels below price (0=off)
+	RSI   int    // RSI period
+	MACD  [3]int // MACD fast, slow and signal periods
+	Stoch [3]int // Stochastic %K, %K smoothing and %D periods
 	// Chart HTTP settings
 	SetWidth, SetHeight, SetMargin string
+	// SortFunc orders charts by symbol, alphabetical if nil
+	SortFunc func(a, b string) bool
+	// Overlays are lines drawn over price by symbol
+	Overlays map[string][]Overlay
+	// MaxPoints charts downsample bars to, 0 uses MAXLIMIT
+	MaxPoints int
+}
+
+// maxPoints returns point cap of chart
+func (c *Chart) maxPoints() int {
+	if c.MaxPoints > 0 {
+		return c.MaxPoints
+	}
+	return MAXLIMIT
+}
+
+// Overlay line, e.g. predicted price or support level
+type Overlay struct {
+	Name   string
+	Color  string
+	Points []Point
+}
+
+// Point on an overlay line
+type Point struct {
+	Time  time.Time
+	Value float64
+}
+
+// AddOverlay adds a line to the chart of symbol
+func (c *Chart) AddOverlay(symbol string, o Overlay) {
+	if c.Overlays == nil {
+		c.Overlays = make(map[string][]Overlay)
+	}
+	c.Overlays[symbol] = append(c.Overlays[symbol], o)
+}
+
+// MakeOverlay = line points in time order
+func MakeOverlay(o Overlay) ([]byte, error) {
+	points := make([]Point, len(o.Points))
+	copy(points, o.Points)
+	sort.SliceStable(points, func(i, j int) bool {
+		return points[i].Time.Before(points[j].Time)
+	})
+
+	var data = make([]interface{}, 0, len(points))
+	for _, p := range points {
+		data = append(data, []interface{}{p.Time.Unix() * 1000, p.Value})
+	}
+	return json.Marshal(&data)
 }
 
 // ChartType ..
@@ -43,6 +106,86 @@ const (
 	Spline ChartType = "spline"
 )
 
+// Theme ..
+type Theme string
+
+const (
+	// Light theme (default)
+	Light Theme = "light"
+	// Dark theme
+	Dark Theme = "dark"
+)
+
+// palette holds theme colors
+type palette struct {
+	page, background, text, grid, tooltip, up, down string
+}
+
+// palette returns colors of chart theme
+func (c *Chart) palette() palette {
+	if c.Theme == Dark {
+		return palette{
+			page:       "#0c0e15",
+			background: "#131722",
+			text:       "#d1d4dc",
+			grid:       "#2a2e39",
+			tooltip:    "#1e222d",
+			up:         "#26a69a",
+			down:       "#ef5350",
+		}
+	}
+	return palette{
+		page:    "whitesmoke",
+		text:    "#707070",
+		tooltip: "white",
+	}
+}
+
+// themeOptions returns Highcharts options for theme colors, empty for light
+func (c *Chart) themeOptions() string {
+	if c.Theme != Dark {
+		return ""
+	}
+
+	p := c.palette()
+	return `,
+		chart: {
+			backgroundColor: '` + p.background + `',
+		},
+		xAxis: {
+			lineColor: '` + p.grid + `',
+			tickColor: '` + p.grid + `',
+			gridLineColor: '` + p.grid + `',
+			labels: { style: { color: '` + p.text + `' } },
+		},
+		yAxis: {
+			gridLineColor: '` + p.grid + `',
+			labels: { style: { color: '` + p.text + `' } },
+			title: { style: { color: '` + p.text + `' } },
+		},
+		tooltip: {
+			style: { color: '` + p.text + `' },
+		},
+		legend: {
+			itemStyle: { color: '` + p.text + `' },
+		},
+		plotOptions: {
+			candlestick: {
+				color: '` + p.down + `',
+				lineColor: '` + p.down + `',
+				upColor: '` + p.up + `',
+				upLineColor: '` + p.up + `',
+			},
+			ohlc: {
+				color: '` + p.down + `',
+				upColor: '` + p.up + `',
+			},
+			column: {
+				borderColor: '` + p.background + `',
+			},
+		},`
+}
+
 // DefaultChart returns default chart settings
 func DefaultChart() *Chart {
 	return &Chart{
@@ -63,48 +206,99 @@ var ohlc = `[
 {"x":1547683200000,"open":3591.84,"high":3634.7,"low":3530.39,"close":3616.21,"name":"test","color":"black"},
 */
 
-// MakeOHLC = price
+// MakeOHLC = price of the last MAXLIMIT bars
 func MakeOHLC(bars history.Bars) ([]byte, error) {
+	return makeOHLC(bars.Window(MAXLIMIT))
+}
+
+// makeOHLC = price of all bars
+func makeOHLC(bars history.Bars) ([]byte, error) {
 	var data []interface{}
 
-	count := int(math.Min(float64(len(bars)), MAXLIMIT))
-	for i := count - 1; i >= 0; i-- {
+	for i := len(bars) - 1; i >= 0; i-- {
 		v := []interface{}{bars[i].Time.Unix() * 1000, bars[i].Open, bars[i].High, bars[i].Low, bars[i].Close}
 		data = append(data, v)
 	}
 	return json.Marshal(&data)
 }
 
-// MakeVolume ..
+// MakeColoredOHLC = price with a color for each of the last MAXLIMIT bars
+func MakeColoredOHLC(bars history.Bars, colors []string) ([]byte, error) {
+	return makeColoredOHLC(bars.Window(MAXLIMIT), colors)
+}
+
+// makeColoredOHLC = price with a color for each bar
+func makeColoredOHLC(bars history.Bars, colors []string) ([]byte, error) {
+	var data []interface{}
+
+	for i := len(bars) - 1; i >= 0; i-- {
+		v := map[string]interface{}{
+			"x":     bars[i].Time.Unix() * 1000,
+			"open":  bars[i].Open,
+			"high":  bars[i].High,
+			"low":   bars[i].Low,
+			"close": bars[i].Close,
+		}
+		if i < len(colors) {
+			v["color"] = colors[i]
+		}
+		data = append(data, v)
+	}
+	return json.Marshal(&data)
+}
+
+// MakeVolume of the last MAXLIMIT bars
 func MakeVolume(bars history.Bars) ([]byte, error) {
+	return makeVolume(bars.Window(MAXLIMIT))
+}
+
+// makeVolume of all bars
+func makeVolume(bars history.Bars) ([]byte, error) {
 	var vol []interface{}
 
-	count := int(math.Min(float64(len(bars)), MAXLIMIT))
-	for i := count - 1; i >= 0; i-- {
+	for i := len(bars) - 1; i >= 0; i-- {
 		v := []interface{}{bars[i].Time.Unix() * 1000, bars[i].Volume}
 		vol = append(vol, v)
 	}
 	return json.Marshal(&vol)
 }
 
-// MakeEventFlags events
-func MakeEventFlags(events history.Events) ([]string, []string) {
-	var buy, sell = make([]string, 0), make([]string, 0)
-
-	for _, event := range events {
-		// s := fmt.Sprintf(`{"x":%d,"title":%q,"text":%q},`, event.Time.Unix()*1000, EventTypes[event.Type], fmt.Sprintf("%s\n%s", event.Title, event.Text))
-
-		if event.Type == 0 || event.Type == 2 || event.Type == 5 {
-			s := fmt.Sprintf(`{"x":%d,"title":"B","text":%q},`, event.Time.Unix()*1000, (event.Name + " " + history.EventTypes[event.Type] + " " + event.Text))
-			buy = append(buy, s)
-		}
-		if event.Type == 1 || event.Type == 3 || event.Type == 4 {
-			s := fmt.Sprintf(`{"x":%d,"title":"S","text":%q},`, event.Time.Unix()*1000, (event.Name + " " + history.EventTypes[event.Type] + " " + event.Text))
-			sell = append(sell, s)
+// MakeEventFlags returns buy and sell flags and forecast points of events
+func MakeEventFlags(events history.Events) (buy, sell, forecast []string) {
+	buy, sell, forecast = make([]string, 0), make([]string, 0), make([]string, 0)
+
+	// forecast spline needs points in time order
+	sorted := make(history.Events, len(events))
+	copy(sorted, events)
+	sort.SliceStable(sorted, func(i, j int) bool {
+		return sorted[i].Time.Before(sorted[j].Time)
+	})
+
+	for _, event := range sorted {
+		x := event.Time.Unix() * 1000
+		text := history.JSString(event.Name + " " + history.EventTypes[event.Type] + " " + event.Text)
+
+		switch event.Type {
+		case history.MARKET_BUY, history.LIMIT_BUY, history.CLOSE_SELL:
+			buy = append(buy, fmt.Sprintf(`{"x":%d,"title":"B","text":%s},`, x, text))
+		case history.MARKET_SELL, history.LIMIT_SELL, history.CLOSE_BUY:
+			sell = append(sell, fmt.Sprintf(`{"x":%d,"title":"S","text":%s},`, x, text))
+		case history.FORECAST:
+			forecast = append(forecast, fmt.Sprintf(`[%d,%v],`, x, event.Price))
 		}
 	}
 
-	return buy, sell
+	return buy, sell, forecast
+}
+
+// chartID returns s with all chars not allowed in a html id replaced with '_'
+func chartID(s string) string {
+	return strings.Map(func(r rune) rune {
+		if r >= 'a' && r <= 'z' || r >= 'A' && r <= 'Z' || r >= '0' && r <= '9' || r == '-' || r == '_' {
+			return r
+		}
+		return '_'
+	}, s)
 }
 
 // MakeHeader creates chart headers
@@ -122,7 +316,7 @@ func (c *Chart) MakeHeader() ([]byte, error) {
 		html{font-family: 'Lato',sans-serif;}
 		body{
 			overflow: auto;
-			background: whitesmoke;
+			background: ` + c.palette().page + `;
 	
 			display: flex;
 			flex-direction: column;
@@ -141,34 +335,60 @@ func (c *Chart) MakeChart(name string, bars history.Bars, events history.Events)
 	if name == "" {
 		name = "unknown"
 	}
-
-	ohlc, err := MakeOHLC(bars)
+	// escape name for html id and js strings
+	id, js := chartID(name), history.JSString(name)
+	// keep the full range of large series. indicators are computed on all bars
+	// and picked at the newest bar of every bucket
+	full := bars
+	idx := full.DownsampleIndex(c.maxPoints())
+	bars = full.Downsample(c.maxPoints())
+	downsampled := len(bars) < len(full)
+
+	var ohlc []byte
+	var err error
+	if c.TrendEMA > 0 {
+		colors := full.ColorByTrend(c.TrendEMA)
+		picked := make([]string, len(idx))
+		for i, n := range idx {
+			picked[i] = colors[n]
+		}
+		ohlc, err = makeColoredOHLC(bars, picked)
+	} else {
+		ohlc, err = makeOHLC(bars)
+	}
 	if err != nil {
 		return nil, err
 	}
 	if len(ohlc) == 0 {
 		return nil, errors.New("no price data")
 	}
+	// oscillator panels
+	panels := c.makePanels(full)
+	for _, p := range panels {
+		for i := range p.lines {
+			p.lines[i].data = pick(p.lines[i].data, idx)
+		}
+	}
 
 	return []byte(`
-	<div class="charts" id="` + name + `"></div>
+	<div class="charts" id="` + id + `"></div>
 	<script>
 
 	Highcharts.setOptions({
 		lang: {
 			rangeSelectorZoom: ''
-		}
+		}` + c.themeOptions() + `
 	});
 
-	Highcharts.stockChart('` + name + `', {
+	Highcharts.stockChart(` + history.JSString(id) + `, {
 		credits: false,
 
 		title: {
-			text: '` + name + `',
+			text: ` + js + `,
 			align: 'left',
 			floating: true,
 			style: {
-			  	color: '#707070',
+			  	color: '` + c.palette().text + `',
 			  	fontSize: '12px',
 			  	fontWeight: 'normal',
 			  	fontStyle: 'none',
@@ -184,6 +404,9 @@ func (c *Chart) MakeChart(name string, bars history.Bars, events history.Events)
 
 		// volume axis if enabled
 		func() string {
+			if len(panels) > 0 {
+				return c.makeYAxis(panels)
+			}
 			if c.Volume {
 				return `
 				yAxis: [{
@@ -205,7 +428,7 @@ func (c *Chart) MakeChart(name string, bars history.Bars, events history.Events)
 		}() + `
 
 		tooltip: {
-			backgroundColor: 'white',
+			backgroundColor: '` + c.palette().tooltip + `',
 			borderWidth: 0,
 			crosshairs: [false, false], // vertial, horizontal
 			hideDelay: 0,
@@ -258,15 +481,15 @@ func (c *Chart) MakeChart(name string, bars history.Bars, events history.Events)
 
 		series: [{
             type: '` + string(c.Type) + `',
-			name: '` + name + `',
-			id: '` + name + `',
+			name: ` + js + `,
+			id: ` + js + `,
 			zIndex: 5,
 			data: ` + string(ohlc) + `,
 			shadow: ` + fmt.Sprintf("%v", c.Shadow) + `,` +
 
 		func() (s string) {
 			// flags data
-			flagB, flagS := MakeEventFlags(events)
+			flagB, flagS, forecast := MakeEventFlags(events)
 
 			// B flag
 			if len(flagB) > 0 {
@@ -275,7 +498,7 @@ func (c *Chart) MakeChart(name string, bars history.Bars, events history.Events)
 					type: 'flags',
 					data: ` + fmt.Sprintf("%s", flagB) + `,
 					zIndex: 19,
-					onSeries: '` + name + `',
+					onSeries: ` + js + `,
 					shape: 'circlepin',
 					color: 'green',
 					fillColor: 'green',
@@ -290,7 +513,7 @@ func (c *Chart) MakeChart(name string, bars history.Bars, events history.Events)
 					type: 'flags',
 					data: ` + fmt.Sprintf("%s", flagS) + `,
 					zIndex: 20,
-					onSeries: '` + name + `',
+					onSeries: ` + js + `,
 					shape: 'circlepin',
 					color: '#f45b5b',
 					fillColor: '#f45b5b',
@@ -299,10 +522,40 @@ func (c *Chart) MakeChart(name string, bars history.Bars, events history.Events)
 					},`
 			}
 
+			// forecast
+			if len(forecast) > 0 {
+				s += `
+				}, {
+					type: 'spline',
+					name: 'Forecast',
+					data: ` + fmt.Sprintf("%s", forecast) + `,
+					zIndex: 6,
+					color: 'gold',
+					dashStyle: 'ShortDash',
+					lineWidth: 2,`
+			}
+
+			// overlays
+			for _, o := range c.Overlays[name] {
+				data, _ := MakeOverlay(o)
+				color := o.Color
+				if color == "" {
+					color = "gold"
+				}
+				s += `
+				}, {
+					type: 'line',
+					name: ` + history.JSString(o.Name) + `,
+					data: ` + string(data) + `,
+					zIndex: 6,
+					color: ` + history.JSString(color) + `,
+					lineWidth: 1,`
+			}
+
 			// volume
 			if c.Volume {
 				// calc volume data
-				volume, _ := MakeVolume(bars)
+				volume, _ := makeVolume(bars)
 				s += `
 				}, {
 					type: 'column',
@@ -313,7 +566,18 @@ func (c *Chart) MakeChart(name string, bars history.Bars, events history.Events)
 					zIndex: 1,
 					shadow: ` + fmt.Sprintf("%v", c.Shadow) + `,`
 				// volume sma
-				if c.VolumeSMA > 0 {
+				if c.VolumeSMA > 0 && downsampled {
+					data, _ := makeSeries(bars, pick(full.SMASeries(c.VolumeSMA, history.V), idx))
+					s += `
+					}, {
+						type: 'line',
+						name: ` + history.JSString(fmt.Sprintf("SMA (%d)", c.VolumeSMA)) + `,
+						data: ` + string(data) + `,
+						yAxis: 1,
+						zIndex: 2,
+						lineWidth: 1,
+						enableMouseTracking: false,`
+				} else if c.VolumeSMA > 0 {
 					s += `
 					}, {
 						type: 'sma',
@@ -325,13 +589,25 @@ func (c *Chart) MakeChart(name string, bars history.Bars, events history.Events)
 						zIndex: 2,`
 				}
 			}
+			// downsampled bars would give sma's and ema's of buckets, use all bars
+			if downsampled {
+				for _, v := range c.SMA {
+					s += c.makeLine(fmt.Sprintf("SMA (%d)", v), bars, pick(full.SMASeries(v, history.C), idx), 4)
+				}
+				for _, v := range c.EMA {
+					s += c.makeLine(fmt.Sprintf("EMA (%d)", v), bars, pick(full.EMASeries(v, history.C), idx), 3)
+				}
+				// oscillator panels
+				s += c.makePanelSeries(bars, panels)
+				return
+			}
 			// sma's
 			if len(c.SMA) > 0 {
 				for _, v := range c.SMA {
 					s += `
 					}, {
 						type: 'sma',
-						linkedTo: '` + name + `',
+						linkedTo: ` + js + `,
 						params: {
 							period: ` + fmt.Sprintf("%v", v) + `,
 						},
@@ -344,13 +620,15 @@ func (c *Chart) MakeChart(name string, bars history.Bars, events history.Events)
 					s += `
 					}, {
 						type: 'ema',
-						linkedTo: '` + name + `',
+						linkedTo: ` + js + `,
 						params: {
 							period: ` + fmt.Sprintf("%v", v) + `,
 						},
 						zIndex: 3,`
 				}
 			}
+			// oscillator panels
+			s += c.makePanelSeries(bars, panels)
 			return
 		}() +
 
@@ -376,6 +654,31 @@ func (c *Chart) MakeChart(name string, bars history.Bars, events history.Events)
 	},
 */
 
+// pick returns values at idx
+func pick(values []float64, idx []int) []float64 {
+	picked := make([]float64, len(idx))
+	for i, n := range idx {
+		picked[i] = math.NaN()
+		if n < len(values) {
+			picked[i] = values[n]
+		}
+	}
+	return picked
+}
+
+// makeLine returns a line series over price of values aligned with bars
+func (c *Chart) makeLine(name string, bars history.Bars, values []float64, z int) string {
+	data, _ := makeSeries(bars, values)
+	return `
+					}, {
+						type: 'line',
+						name: ` + history.JSString(name) + `,
+						data: ` + string(data) + `,
+						zIndex: ` + fmt.Sprintf("%d", z) + `,
+						lineWidth: 1,
+						enableMouseTracking: false,`
+}
+
 // Chart ..
 func (c *Chart) BuildCharts(m map[string]history.Bars, events map[string]history.Events) (buf []byte, err error) {
 	if len(m) == 0 {
@@ -389,7 +692,8 @@ func (c *Chart) BuildCharts(m map[string]history.Bars, events map[string]history
 
 	if len(events) > 0 {
 		// make map of all events for given symbol
-		for symbol, ev := range events {
+		for _, symbol := range sortSymbols(events, c.SortFunc) {
+			ev := events[symbol]
 			// get bars of symbol
 			bars, ok := m[symbol]
 			if !ok {
@@ -405,9 +709,9 @@ func (c *Chart) BuildCharts(m map[string]history.Bars, events map[string]history
 		}
 
 	} else {
-		for symbol, bars := range m {
+		for _, symbol := range sortSymbols(m, c.SortFunc) {
 
-			chart, err := c.MakeChart(symbol, bars, nil)
+			chart, err := c.MakeChart(symbol, m[symbol], nil)
 			if err != nil {
 				log.Println(err)
 			}
@@ -418,3 +722,54 @@ func (c *Chart) BuildCharts(m map[string]history.Bars, events map[string]history
 
 	return buf, err
 }
+
+// WriteFile builds charts and saves them to a html file
+func (c *Chart) WriteFile(filename string, m map[string]history.Bars, events map[string]history.Events) error {
+	buf, err := c.BuildCharts(m, events)
+	if err != nil {
+		return err
+	}
+
+	return os.WriteFile(filename, buf, 0644)
+}
+
+// Handler serves charts of all history bars, or only one with '?symbol=BTCUSDT1d'
+func (c *Chart) Handler(hist *history.History) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		m := hist.Map()
+
+		if symbol := r.URL.Query().Get("symbol"); symbol != "" {
+			bars, ok := m[symbol]
+			if !ok {
+				http.Error(w, symbol+" not found", http.StatusNotFound)
+				return
+			}
+			m = map[string]history.Bars{symbol: bars}
+		}
+
+		buf, err := c.BuildCharts(m, nil)
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusInternalServerError)
+			return
+		}
+		w.Write(buf)
+	})
+}
+
+// sortSymbols returns keys of m ordered by less, alphabetical if nil
+func sortSymbols[T any](m map[string]T, less func(a, b string) bool) []string {
+	symbols := make([]string, 0, len(m))
+	for symbol := range m {
+		symbols = append(symbols, symbol)
+	}
+
+	if less == nil {
+		sort.Strings(symbols)
+	} else {
+		sort.SliceStable(symbols, func(i, j int) bool {
+			return less(symbols[i], symbols[j])
+		})
+	}
+
+	return symbols
+}
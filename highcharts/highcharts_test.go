@@ -0,0 +1,85 @@
+package highcharts
+
+import (
+	"encoding/json"
+	"math"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/slicken/history"
+)
+
+// makeBars returns n hourly bars, newest first
+func makeBars(n int) history.Bars {
+	start := time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)
+	bars := make(history.Bars, n)
+	for i := 0; i < n; i++ {
+		c := 100 + 10*math.Sin(float64(i)/7)
+		bars[n-1-i] = history.Bar{Time: start.Add(time.Duration(i) * time.Hour), Open: c, High: c + 1, Low: c - 1, Close: c, Volume: 1}
+	}
+	return bars
+}
+
+func TestDownsampledIndicatorsUseAllBars(t *testing.T) {
+	bars := makeBars(1000)
+	c := DefaultChart()
+	c.MaxPoints = 100
+	c.RSI = 14
+	c.SMA = []int{50}
+
+	buf, err := c.MakeChart("BTCUSDT1h", bars, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	out := string(buf)
+
+	// last values are the ones of all bars, not of buckets
+	rsi, _ := json.Marshal([]interface{}{bars[0].Time.Unix() * 1000, bars.RSISeries(14)[0]})
+	if !strings.Contains(out, string(rsi)) {
+		t.Errorf("rsi of all bars %s not in chart", rsi)
+	}
+	sma, _ := json.Marshal([]interface{}{bars[0].Time.Unix() * 1000, bars.SMASeries(50, history.C)[0]})
+	if !strings.Contains(out, string(sma)) {
+		t.Errorf("sma of all bars %s not in chart", sma)
+	}
+	if strings.Contains(out, "linkedTo") {
+		t.Error("downsampled chart uses client side indicators")
+	}
+}
+
+func TestMakeChartEscapesSymbol(t *testing.T) {
+	name := `x</script><script>alert("x")</script>`
+	events := history.Events{history.BuyBracket(name, makeBars(1)[0].Time, 1, 100, 0, 0)}
+	events[0].Text = `<img src=x onerror=alert(1)>`
+
+	buf, err := DefaultChart().MakeChart(name, makeBars(50), events)
+	if err != nil {
+		t.Fatal(err)
+	}
+	out := string(buf)
+	for _, s := range []string{"</script><script>", "alert(\"x\")", "<img"} {
+		if strings.Contains(out, s) {
+			t.Errorf("chart contains unescaped %q", s)
+		}
+	}
+	if !strings.Contains(out, `\u003c/script\u003e`) {
+		t.Error("chart does not contain the escaped name")
+	}
+}
+
+func TestMakeColoredOHLC(t *testing.T) {
+	bars := makeBars(3)
+	buf, err := MakeColoredOHLC(bars, []string{"green", "red", "gray"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	var data []map[string]interface{}
+	if err := json.Unmarshal(buf, &data); err != nil {
+		t.Fatal(err)
+	}
+	// oldest bar first
+	if len(data) != 3 || data[0]["color"] != "gray" || data[2]["color"] != "green" {
+		t.Fatalf("got %s", buf)
+	}
+}
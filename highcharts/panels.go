@@ -0,0 +1,154 @@
+package highcharts
+
+import (
+	"encoding/json"
+	"fmt"
+	"math"
+
+	"github.com/slicken/history"
+)
+
+// panel is an oscillator chart below price and volume
+type panel struct {
+	title string
+	lines []line
+	// fixed axis range, auto if min == max
+	min, max float64
+}
+
+// line is one series in a panel
+type line struct {
+	name, kind, color string
+	data              []float64
+}
+
+// MakeSeries = indicator values aligned with the last MAXLIMIT bars, NaN values are skipped
+func MakeSeries(bars history.Bars, series []float64) ([]byte, error) {
+	return makeSeries(bars.Window(MAXLIMIT), series)
+}
+
+// makeSeries = indicator values aligned with bars, NaN values are skipped
+func makeSeries(bars history.Bars, series []float64) ([]byte, error) {
+	var data = make([]interface{}, 0)
+
+	for i := len(bars) - 1; i >= 0; i-- {
+		if i >= len(series) || math.IsNaN(series[i]) {
+			continue
+		}
+		v := []interface{}{bars[i].Time.Unix() * 1000, series[i]}
+		data = append(data, v)
+	}
+	return json.Marshal(&data)
+}
+
+// makePanels computes oscillator panels enabled in chart settings
+func (c *Chart) makePanels(bars history.Bars) []panel {
+	var panels []panel
+
+	if c.RSI > 0 {
+		panels = append(panels, panel{
+			title: fmt.Sprintf("RSI(%d)", c.RSI),
+			lines: []line{{"RSI", "line", "#7e57c2", bars.RSISeries(c.RSI)}},
+			min:   0,
+			max:   100,
+		})
+	}
+	if c.MACD[0] > 0 && c.MACD[1] > 0 && c.MACD[2] > 0 {
+		macd, sig, hist := bars.MACDSeries(c.MACD[0], c.MACD[1], c.MACD[2])
+		panels = append(panels, panel{
+			title: fmt.Sprintf("MACD(%d,%d,%d)", c.MACD[0], c.MACD[1], c.MACD[2]),
+			lines: []line{
+				{"Histogram", "column", "#b0bec5", hist},
+				{"MACD", "line", "#2962ff", macd},
+				{"Signal", "line", "#ff6d00", sig},
+			},
+		})
+	}
+	if c.Stoch[0] > 0 && c.Stoch[1] > 0 && c.Stoch[2] > 0 {
+		k, d := bars.StochSeries(c.Stoch[0], c.Stoch[1], c.Stoch[2])
+		panels = append(panels, panel{
+			title: fmt.Sprintf("Stoch(%d,%d,%d)", c.Stoch[0], c.Stoch[1], c.Stoch[2]),
+			lines: []line{
+				{"%K", "line", "#2962ff", k},
+				{"%D", "line", "#ff6d00", d},
+			},
+			min: 0,
+			max: 100,
+		})
+	}
+
+	return panels
+}
+
+// makeYAxis for price, volume (if enabled) and panels stacked below each other
+func (c *Chart) makeYAxis(panels []panel) string {
+	n := len(panels)
+	if c.Volume {
+		n++
+	}
+	// price gets at least 40%
+	height := 20
+	if 100-height*n < 40 {
+		height = 60 / n
+	}
+	top := 100 - height*n
+
+	s := `
+				yAxis: [{
+					gridLineWidth: 0,
+					lineWidth: 0,
+					height: '` + fmt.Sprintf("%d%%", top) + `',`
+	if c.Volume {
+		s += `
+				}, {
+					gridLineWidth: 0,
+					lineWidth: 0,
+					height: '` + fmt.Sprintf("%d%%", height) + `',
+					top: '` + fmt.Sprintf("%d%%", top) + `',`
+		top += height
+	}
+	for _, p := range panels {
+		s += `
+				}, {
+					title: {
+						text: ` + history.JSString(p.title) + `,
+					},
+					gridLineWidth: 0,
+					lineWidth: 1,
+					offset: 0,
+					height: '` + fmt.Sprintf("%d%%", height) + `',
+					top: '` + fmt.Sprintf("%d%%", top) + `',`
+		if p.min != p.max {
+			s += `
+					min: ` + fmt.Sprintf("%v", p.min) + `,
+					max: ` + fmt.Sprintf("%v", p.max) + `,`
+		}
+		top += height
+	}
+	return s + `
+				}],`
+}
+
+// makePanelSeries returns series of all panels, axis index follows price and volume
+func (c *Chart) makePanelSeries(bars history.Bars, panels []panel) (s string) {
+	axis := 1
+	if c.Volume {
+		axis = 2
+	}
+
+	for i, p := range panels {
+		for _, l := range p.lines {
+			data, _ := makeSeries(bars, l.data)
+			s += `
+				}, {
+					type: '` + l.kind + `',
+					name: ` + history.JSString(l.name) + `,
+					data: ` + string(data) + `,
+					yAxis: ` + fmt.Sprintf("%d", axis+i) + `,
+					color: '` + l.color + `',
+					lineWidth: 1,
+					zIndex: 2,`
+		}
+	}
+	return s
+}
@@ -0,0 +1,120 @@
+package highcharts
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"html"
+	"os"
+
+	"github.com/slicken/history"
+)
+
+// MakeEquity = balance curve of portfolio
+func MakeEquity(p *history.Portfolio) ([]byte, error) {
+	var data = make([]interface{}, 0)
+	for _, e := range p.Equity() {
+		data = append(data, []interface{}{e.Time.Unix() * 1000, e.Balance})
+	}
+	return json.Marshal(&data)
+}
+
+// makeStats returns a html table of portfolio stats
+func (c *Chart) makeStats(p *history.Portfolio) []byte {
+	stats := p.Stats
+	rows := [][2]string{
+		{"Initial balance", fmt.Sprintf("%.2f", p.Initial())},
+		{"Final balance", fmt.Sprintf("%.2f (%.2f%%)", p.Value(), p.Gain())},
+		{"Open positions", fmt.Sprintf("%d (%.2f at cost)", len(p.Open), p.Value()-p.Balance)},
+		{"Trades", fmt.Sprintf("%d", stats.TotalTrades)},
+		{"Win rate", fmt.Sprintf("%.1f%% (%d/%d)", stats.WinRate, stats.WinningTrades, stats.WinningTrades+stats.LosingTrades)},
+		{"Profit factor", fmt.Sprintf("%.2f", stats.ProfitFactor)},
+		{"Expectancy", fmt.Sprintf("%.2f", stats.Expectancy)},
+		{"Max drawdown", fmt.Sprintf("%.2f%%", p.MaxDrawdown())},
+		{"Sharpe (per trade)", fmt.Sprintf("%.2f", p.Sharpe())},
+	}
+
+	buf := []byte(`
+	<table class="charts" style="color: ` + c.palette().text + `; height: auto;">`)
+	for _, row := range rows {
+		buf = append(buf, `
+		<tr><td>`+html.EscapeString(row[0])+`</td><td style="text-align: right;">`+html.EscapeString(row[1])+`</td></tr>`...)
+	}
+	return append(buf, `
+	</table>`...)
+}
+
+// makeEquityChart returns a line chart of portfolio balance
+func (c *Chart) makeEquityChart(name string, p *history.Portfolio) ([]byte, error) {
+	equity, err := MakeEquity(p)
+	if err != nil {
+		return nil, err
+	}
+	id := chartID(name + "_equity")
+
+	return []byte(`
+	<div class="charts" id="` + id + `" style="height: 300px;"></div>
+	<script>
+	Highcharts.stockChart(` + history.JSString(id) + `, {
+		credits: false,
+		title: {
+			text: 'Equity',
+			align: 'left',
+			floating: true,
+			style: {
+				color: '` + c.palette().text + `',
+				fontSize: '12px',
+			}
+		},
+		rangeSelector: { enabled: false },
+		navigator: { enabled: false },
+		scrollbar: { enabled: false },
+		series: [{
+			type: 'line',
+			name: 'Balance',
+			data: ` + string(equity) + `,
+			step: 'left',
+		}]
+	});
+	</script>`), nil
+}
+
+// Report builds one html page of a test result with a stats table, the equity curve
+// and the price chart with entry and exit flags
+func (c *Chart) Report(r *history.TestResult, bars history.Bars) ([]byte, error) {
+	if r == nil {
+		return nil, errors.New("no test result")
+	}
+	p := r.Portfolio
+	if p == nil {
+		p = history.NewPortfolio(0)
+	}
+
+	buf, err := c.MakeHeader()
+	if err != nil {
+		return nil, err
+	}
+	buf = append(buf, c.makeStats(p)...)
+
+	equity, err := c.makeEquityChart(r.Symbol, p)
+	if err != nil {
+		return nil, err
+	}
+	buf = append(buf, equity...)
+
+	chart, err := c.MakeChart(r.Symbol, bars, r.Events)
+	if err != nil {
+		return nil, err
+	}
+	return append(buf, chart...), nil
+}
+
+// WriteReport saves Report to a html file
+func (c *Chart) WriteReport(filename string, r *history.TestResult, bars history.Bars) error {
+	buf, err := c.Report(r, bars)
+	if err != nil {
+		return err
+	}
+
+	return os.WriteFile(filename, buf, 0644)
+}
@@ -7,10 +7,11 @@
 	Portfolio 						for tracking gains when backtesting
 
 	History.bars[symbol]Bars		Bars = []Bar
-	History.Tick[symbol]float64		interface for Tickdata from ws::
+	History.Tick					forming bar of polled symbols, streamed symbols send none
 	History.Update(true)			if true, it will update if new bars
 	History.Downloader 				Interface where you connect you downloader and stores Bars data
-	History.C						notify when symbol (pair+timeframe) get new data (bars)
+	History.C						notify when symbol (pair+timeframe) get new data (bars), best effort
+	History.Subscribe()				every new bars of all symbols, for each subscriber
 
 	Important things to be aware of
 	Tf		 	= Timeframe
@@ -35,9 +36,13 @@
 package history
 
 import (
+	"context"
 	"errors"
 	"fmt"
 	"log"
+	"math/rand"
+	"os"
+	"sort"
 	"sync"
 	"time"
 )
@@ -46,19 +51,87 @@ import (
 type History struct {
 	bars   map[string]Bars
 	update bool
-	// C notify channel when we got now bars for a history (symbol)
+	// maxlimit of bars per download, 0 uses the default
+	maxlimit int
+	clock    Clock
+	// retry policy for downloads
+	maxTries int
+	backoff  time.Duration
+	// starting balance of PortfolioTest
+	balance float64
+	// progress of tests, called when a symbol is done
+	progress func(done, total int)
+	// parallel runs tests on symbols in parallel
+	parallel bool
+	// nopersist keeps new bars in memory only
+	nopersist bool
+	// info holds trading rules by symbol or pair
+	info map[string]SymbolInfo
+	// streams of symbols when Downloader is a KlineStreamer
+	streams map[string]*stream
+	// cache of stored bars, nil if disabled
+	cache *barCache
+	// subscribers of bar updates
+	subscribers []*subscriber
+	// C notify channel when we got now bars for a history (symbol).
+	// notifications are dropped when it is full, use Subscribe to get every update
 	C chan string
+	// Errors receives download errors after all retries failed, if not nil
+	Errors chan error
+	// Tick receives the forming bar of every download, if not nil.
+	// tick bars are provisional, they change until the bar closes and are never saved.
+	// only polled symbols send ticks, streams of a KlineStreamer send closed bars only
+	Tick chan TickBar
 	// Plug diffrent downloaders
 	Downloader
 
 	sync.RWMutex
 }
 
+// Option configures a History in NewHistory
+type Option func(*History)
+
+// WithMaxLimit limits bars per download request
+func WithMaxLimit(v int) Option {
+	return func(h *History) {
+		h.maxlimit = v
+	}
+}
+
+// NewHistory returns a History with options, new(History) works with defaults
+func NewHistory(options ...Option) *History {
+	h := new(History)
+	for _, option := range options {
+		option(h)
+	}
+	return h
+}
+
+// TickBar is the unfinished current bar of a symbol
+type TickBar struct {
+	Symbol string
+	Bar
+}
+
 // Downloader interface plugs functions that download bars
 type Downloader interface {
 	GetKlines(pair, timeframe string, limit int) (Bars, error)
 }
 
+// KlineStreamer is an optional Downloader interface for exchanges with websockets.
+// StreamKlines sends closed bars until ctx is done, and closes the channel when
+// the stream ends. history prefers streams and only polls symbols that fall behind,
+// so streamed symbols do not send to Tick
+type KlineStreamer interface {
+	StreamKlines(ctx context.Context, symbol, tf string) (<-chan Bar, error)
+}
+
+// stream of a symbol
+type stream struct {
+	cancel context.CancelFunc
+	failed bool
+}
+
 // Bars returns bars saftly
 func (h *History) Bars(symbol string) Bars {
 	h.RLock()
@@ -72,9 +145,54 @@ func (h *History) Bars(symbol string) Bars {
 	return bars
 }
 
-// Returns a map of all bars
+// Map returns a shallow copy of all bars, safe to range over
+// while history is updating
 func (h *History) Map() map[string]Bars {
-	return h.bars
+	h.RLock()
+	defer h.RUnlock()
+
+	m := make(map[string]Bars, len(h.bars))
+	for symbol, bars := range h.bars {
+		m[symbol] = bars
+	}
+
+	return m
+}
+
+// Timeframes returns distinct timeframes of loaded symbols, shortest first
+func (h *History) Timeframes() []string {
+	h.RLock()
+	defer h.RUnlock()
+
+	seen := make(map[string]bool)
+	var tfs []string
+	for symbol := range h.bars {
+		if _, tf := SplitSymbol(symbol); !seen[tf] {
+			seen[tf] = true
+			tfs = append(tfs, tf)
+		}
+	}
+	sort.Slice(tfs, func(i, j int) bool {
+		return TFInterval(tfs[i]) < TFInterval(tfs[j])
+	})
+	return tfs
+}
+
+// Pairs returns distinct pairs of loaded symbols in sorted order
+func (h *History) Pairs() []string {
+	h.RLock()
+	defer h.RUnlock()
+
+	seen := make(map[string]bool)
+	var pairs []string
+	for symbol := range h.bars {
+		if pair, _ := SplitSymbol(symbol); !seen[pair] {
+			seen[pair] = true
+			pairs = append(pairs, pair)
+		}
+	}
+	sort.Strings(pairs)
+	return pairs
 }
 
 // MinPeriod returns minimum period of historys
@@ -127,6 +245,35 @@ func (h *History) LastTime() time.Time {
 	return v
 }
 
+// SymbolStats of loaded bars of a symbol
+type SymbolStats struct {
+	Symbol      string
+	Bars        int
+	First, Last time.Time
+	Period      time.Duration
+	Gaps        int
+}
+
+func (s SymbolStats) String() string {
+	return fmt.Sprintf("%s: %d bars, %s..%s, %d gaps", s.Symbol, s.Bars, s.First.Format("2006-01-02"), s.Last.Format("2006-01-02"), s.Gaps)
+}
+
+// Stats returns bar count and coverage of every loaded symbol
+func (h *History) Stats() map[string]SymbolStats {
+	stats := make(map[string]SymbolStats)
+	for symbol, bars := range h.Map() {
+		stats[symbol] = SymbolStats{
+			Symbol: symbol,
+			Bars:   len(bars),
+			First:  bars.FirstBar().Time,
+			Last:   bars.LastBar().Time,
+			Period: bars.Period(),
+			Gaps:   len(bars.Gaps()),
+		}
+	}
+	return stats
+}
+
 // Limit the data for all history
 func (h *History) Limit(length int) *History {
 	h.Lock()
@@ -142,6 +289,39 @@ func (h *History) Limit(length int) *History {
 	return h
 }
 
+// Resample aggregates bars of pair from timeframe fromTF to toTF and adds them as a new symbol.
+// source bars are taken from history, or from file if not loaded. the newest and oldest bars
+// are dropped if they are not complete. toTF must be a multiple of fromTF
+func (h *History) Resample(pair, fromTF, toTF string) error {
+	from, to := TFInterval(fromTF), TFInterval(toTF)
+	if from == 0 || to == 0 {
+		return fmt.Errorf("invalid timeframe %s or %s", fromTF, toTF)
+	}
+	if to <= from || to%from != 0 {
+		return fmt.Errorf("%s is not a multiple of %s", toTF, fromTF)
+	}
+
+	symbol := pair + TFString(from)
+	bars := h.Bars(symbol)
+	if len(bars) == 0 {
+		var err error
+		if bars, err = h.ReadBars(symbol); err != nil {
+			return err
+		}
+	}
+
+	resampled := bars.Resample(to)
+	if len(resampled) > 0 && resampled[0].Time.Add(to.Duration()).After(bars[0].Time.Add(from.Duration())) {
+		resampled = resampled[1:]
+	}
+	// oldest source bar starts after its bucket
+	if n := len(resampled) - 1; n >= 0 && bars.FirstBar().Time.After(resampled[n].Time) {
+		resampled = resampled[:n]
+	}
+
+	return h.Add(pair+TFString(to), resampled)
+}
+
 // LimiTimeSpan the data for specified data time intervalls
 func (h *History) LimitTimeSpan(start, end time.Time) *History {
 	// h.Lock()
@@ -164,6 +344,22 @@ func (h *History) LimitTimeSpan(start, end time.Time) *History {
 	return h
 }
 
+// FilterLiquid unloads symbols where the average dollar volume
+// over all loaded bars is less then minDollarVol
+func (h *History) FilterLiquid(minDollarVol float64) *History {
+	h.Lock()
+	defer h.Unlock()
+
+	for symbol, bars := range h.bars {
+		if bars.DollarVolume(0) < minDollarVol {
+			delete(h.bars, symbol)
+			log.Println(symbol, "unloaded (illiquid)")
+		}
+	}
+
+	return h
+}
+
 // need fixing
 // Unload removes symbol bars from data struct gracefully
 func (h *History) Unload(symbol string) error {
@@ -198,7 +394,7 @@ func (h *History) Load(symbols ...string) error {
 			defer wg.Done()
 
 			// we add ether way
-			bars, _ := ReadBars(symbol)
+			bars, _ := h.ReadBars(symbol)
 			h.Add(symbol, bars)
 		}(symbol, &wg)
 	}
@@ -207,6 +403,9 @@ func (h *History) Load(symbols ...string) error {
 	return nil
 }
 
+// errNoNewBars is returned by Add if bars are already in history
+var errNoNewBars = errors.New("no new bars")
+
 // Add new history safely to datastruct
 func (h *History) Add(symbol string, bars Bars) error {
 	h.Lock()
@@ -223,26 +422,21 @@ func (h *History) Add(symbol string, bars Bars) error {
 		msg = "loading"
 
 		h.bars[symbol] = bars
-		// increase cap by +1
-		c := make(chan string, len(h.bars))
-		// copy values to new channel
-		for len(h.C) > 0 {
-			select {
-			case v := <-h.C:
-				c <- v
-			default:
-			}
+		if h.C == nil {
+			h.C = make(chan string, csize)
 		}
-		h.C = c
 	} else if len(b) == len(bars) && b.LastBar() == bars.LastBar() {
 		// nothing new
-		return errors.New("no new bars")
+		return errNoNewBars
 	} else {
 		// save bars
 		msg = fmt.Sprintf("added %d bars", len(bars))
-		if err := WriteBars(symbol, bars); err != nil {
-			log.Printf("could not save %s bars: %v\n", symbol, err)
+		if !h.nopersist {
+			if err := WriteBars(symbol, bars); err != nil {
+				log.Printf("could not save %s bars: %v\n", symbol, err)
+			}
 		}
+		h.invalidate(symbol)
 	}
 	if len(bars) == 0 {
 		return nil
@@ -258,6 +452,52 @@ func (h *History) Add(symbol string, bars Bars) error {
 	}
 
 	log.Println(symbol, msg)
+	h.publish(symbol, bars)
+
+	// notify data.C that we have bars
+	select {
+	case h.C <- (symbol):
+	default:
+	}
+
+	return nil
+}
+
+// Correct overwrites bars of symbol at the same times, in history and file,
+// and notifies C so strategies can run again
+func (h *History) Correct(symbol string, bars Bars) error {
+	h.Lock()
+	defer h.Unlock()
+
+	old, ok := h.bars[symbol]
+	if !ok {
+		return fmt.Errorf("%s not loaded", symbol)
+	}
+	if len(bars) == 0 {
+		return errors.New("no bars")
+	}
+	merged := merge(old, bars)
+
+	if !h.nopersist {
+		// keep stored bars that are not in history
+		mu := fileLock(barsFile(symbol))
+		mu.Lock()
+		stored, err := ReadBars(symbol)
+		if err != nil && !os.IsNotExist(err) {
+			mu.Unlock()
+			return err
+		}
+		err = writeBars(symbol, merge(stored, merged))
+		mu.Unlock()
+		h.invalidate(symbol)
+		if err != nil {
+			return err
+		}
+	}
+	h.bars[symbol] = merged
+
+	log.Println(symbol, fmt.Sprintf("corrected %d bars", len(bars)))
+	h.publish(symbol, bars)
 
 	// notify data.C that we have bars
 	select {
@@ -275,30 +515,43 @@ func (h *History) Update(enabled bool) {
 	h.update = enabled
 	h.Unlock()
 
-	var done bool
-	go func(w *bool) {
+	// first is closed after the first update
+	first := make(chan struct{})
+	var once sync.Once
+	go func() {
+		defer once.Do(func() { close(first) })
+		defer h.stopStreams()
 		for {
 			h.RLock()
-			enabled = h.update
+			enabled := h.update
+			streamer, _ := h.Downloader.(KlineStreamer)
 			h.RUnlock()
 			if !enabled {
-				done = true
 				return
 			}
+			if streamer != nil {
+				h.startStreams(streamer)
+			}
 
 			h.RLock()
 			var wg sync.WaitGroup
+			max := h.maxLimit()
 			for symbol := range h.bars {
-				limit := maxlimit
+				limit := max
 
 				// calc how many new bars we can download from our last bar
 				if len(h.bars[symbol]) > 0 {
-					limit = calcLimit(h.bars[symbol].LastBar().T(), h.bars[symbol].Period())
-					if limit > maxlimit {
-						limit = maxlimit
+					limit = calcLimit(h.now(), h.bars[symbol].LastBar().T(), h.bars[symbol].Period())
+					if limit > max {
+						limit = max
 					}
 				}
 
+				// streams add bars when they close, poll only if behind
+				if s, ok := h.streams[symbol]; ok && !s.failed && limit <= 2 {
+					continue
+				}
+
 				if limit > 1 {
 					wg.Add(1)
 					go h.download(symbol, limit, &wg)
@@ -307,16 +560,75 @@ func (h *History) Update(enabled bool) {
 			h.RUnlock()
 
 			wg.Wait()
-			done = true
+			once.Do(func() { close(first) })
 
 			time.Sleep(time.Second)
 		}
-	}(&done)
+	}()
 
 	// wait for first update
-	for !done {
-		time.Sleep(100 * time.Microsecond)
+	<-first
+}
+
+// startStreams starts streams of symbols that have none
+func (h *History) startStreams(streamer KlineStreamer) {
+	h.Lock()
+	if h.streams == nil {
+		h.streams = make(map[string]*stream)
+	}
+	var symbols []string
+	for symbol := range h.bars {
+		if _, ok := h.streams[symbol]; !ok {
+			symbols = append(symbols, symbol)
+		}
+	}
+	h.Unlock()
+
+	for _, symbol := range symbols {
+		pair, tf := SplitSymbol(symbol)
+		ctx, cancel := context.WithCancel(context.Background())
+		s := &stream{cancel: cancel}
+
+		c, err := streamer.StreamKlines(ctx, pair, tf)
+		if err != nil {
+			// keep s so symbol is polled and not retried every loop
+			cancel()
+			s.failed = true
+			log.Printf("could not stream %s: %v\n", symbol, err)
+			h.Lock()
+			h.streams[symbol] = s
+			h.Unlock()
+			continue
+		}
+
+		h.Lock()
+		h.streams[symbol] = s
+		h.Unlock()
+
+		go func(symbol string) {
+			for bar := range c {
+				h.Add(symbol, Bars{bar})
+			}
+			cancel()
+			// remove so stream restarts on next loop
+			h.Lock()
+			if h.streams[symbol] == s {
+				delete(h.streams, symbol)
+			}
+			h.Unlock()
+		}(symbol)
+	}
+}
+
+// stopStreams cancels all streams
+func (h *History) stopStreams() {
+	h.Lock()
+	defer h.Unlock()
+
+	for _, s := range h.streams {
+		s.cancel()
 	}
+	h.streams = nil
 }
 
 // download and check validity before adding to history
@@ -325,20 +637,35 @@ func (h *History) download(symbol string, limit int, wg *sync.WaitGroup) error {
 
 	pair, tf := SplitSymbol(symbol)
 
-	var err error
-	var bars Bars
-	bars, err = h.GetKlines(pair, tf, limit)
+	bars, err := h.getKlines(pair, tf, limit)
 	if err != nil {
 		log.Printf("failed to download %d bars for %s: %v\n", limit, symbol, err)
-		time.Sleep(2 * time.Minute)
+		// notify Errors if anyone listens
+		select {
+		case h.Errors <- fmt.Errorf("%s: %v", symbol, err):
+		default:
+		}
 		return err
 	}
+	// send the forming bar if anyone listens, it is not saved
+	if len(bars) > 0 {
+		h.RLock()
+		tick := h.Tick
+		h.RUnlock()
+		select {
+		case tick <- TickBar{symbol, bars[0]}:
+		default:
+		}
+	}
 	// since we always get the current bar witch is not finish, we dont want to save that
 	if 2 > len(bars) {
 		return nil
 	}
 	// check if lastbar time is fresh, if not then delete symbol from history (not file)
-	if time.Now().Add(2 * -bars.Period()).After(bars.LastBar().T()) {
+	h.RLock()
+	now := h.now()
+	h.RUnlock()
+	if now.Add(2 * -bars.Period()).After(bars.LastBar().T()) {
 		h.Lock()
 		delete(h.bars, symbol)
 		h.Unlock()
@@ -349,3 +676,31 @@ func (h *History) download(symbol string, limit int, wg *sync.WaitGroup) error {
 	h.Add(symbol, bars[1:])
 	return nil
 }
+
+// getKlines downloads bars, retrying with exponential backoff and jitter
+func (h *History) getKlines(pair, tf string, limit int) (Bars, error) {
+	h.RLock()
+	tries, backoff := h.maxTries, h.backoff
+	h.RUnlock()
+	if tries < 1 {
+		tries = maxtries
+	}
+	if backoff <= 0 {
+		backoff = minbackoff
+	}
+
+	var err error
+	var bars Bars
+	for n := 0; n < tries; n++ {
+		if n > 0 {
+			// sleep between half and full backoff, doubling every try
+			d := backoff << (n - 1)
+			time.Sleep(d/2 + time.Duration(rand.Int63n(int64(d/2)+1)))
+		}
+		if bars, err = h.GetKlines(pair, tf, limit); err == nil {
+			return bars, nil
+		}
+	}
+
+	return nil, err
+}
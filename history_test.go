@@ -0,0 +1,204 @@
+package history
+
+import (
+	"fmt"
+	"io"
+	"log"
+	"os"
+	"path/filepath"
+	"sync"
+	"testing"
+	"time"
+)
+
+func TestMain(m *testing.M) {
+	log.SetOutput(io.Discard)
+	os.Exit(m.Run())
+}
+
+// start is the time of the oldest test bar
+var start = time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)
+
+// makeBars returns n bars of period from start, newest first, with close from closes
+// repeated or rising by one if closes is empty
+func makeBars(n int, period time.Duration, closes ...float64) Bars {
+	bars := make(Bars, n)
+	for i := 0; i < n; i++ {
+		c := float64(100 + i)
+		if len(closes) > 0 {
+			c = closes[i%len(closes)]
+		}
+		bars[n-1-i] = Bar{
+			Time:   start.Add(time.Duration(i) * period),
+			Open:   c,
+			High:   c + 1,
+			Low:    c - 1,
+			Close:  c,
+			Volume: 10,
+		}
+	}
+	return bars
+}
+
+// newTestHistory returns a History with its own datadir
+func newTestHistory(t *testing.T) *History {
+	t.Helper()
+	h := NewHistory()
+	h.SetDataDir(t.TempDir())
+	return h
+}
+
+func TestConcurrentAddAndMap(t *testing.T) {
+	h := newTestHistory(t)
+	h.SetPersist(false)
+
+	var wg sync.WaitGroup
+	for n := 0; n < 8; n++ {
+		wg.Add(2)
+		go func(n int) {
+			defer wg.Done()
+			symbol := fmt.Sprintf("S%dUSDT1h", n)
+			bars := makeBars(50, time.Hour)
+			h.Add(symbol, bars[10:])
+			for i := 9; i >= 0; i-- {
+				h.Add(symbol, bars[i:i+1])
+			}
+		}(n)
+		go func() {
+			defer wg.Done()
+			for i := 0; i < 100; i++ {
+				for symbol, bars := range h.Map() {
+					if len(bars) == 0 {
+						t.Errorf("%s has no bars", symbol)
+					}
+				}
+			}
+		}()
+	}
+	wg.Wait()
+
+	m := h.Map()
+	if len(m) != 8 {
+		t.Fatalf("got %d symbols, want 8", len(m))
+	}
+	for symbol, bars := range m {
+		if len(bars) != 50 {
+			t.Errorf("%s has %d bars, want 50", symbol, len(bars))
+		}
+	}
+}
+
+func TestResampleDropsPartialBuckets(t *testing.T) {
+	h := newTestHistory(t)
+	h.SetPersist(false)
+	// 1h bars from 02:00 to 17:00, so 00:00 and 16:00 4h buckets are partial
+	bars := makeBars(16, time.Hour)
+	for i := range bars {
+		bars[i].Time = bars[i].Time.Add(2 * time.Hour)
+	}
+	h.Add("BTCUSDT1h", bars)
+
+	if err := h.Resample("BTCUSDT", "1h", "4h"); err != nil {
+		t.Fatal(err)
+	}
+	got := h.Bars("BTCUSDT4h")
+	if len(got) != 3 || !got[0].Time.Equal(start.Add(12*time.Hour)) {
+		t.Fatalf("got %d bars, want 3 from 04:00 to 12:00", len(got))
+	}
+	oldest := got.FirstBar()
+	if !oldest.Time.Equal(start.Add(4*time.Hour)) || oldest.Open != 102 || oldest.Close != 105 {
+		t.Fatalf("got %v, want 04:00 bar with open 102 and close 105", oldest)
+	}
+}
+
+func TestNoPersistWritesNothing(t *testing.T) {
+	h := newTestHistory(t)
+	h.SetPersist(false)
+	bars := makeBars(12, time.Hour)
+	src := t.TempDir()
+	if err := bars[2:].WriteJSON(filepath.Join(src, "ETHUSDT1h.json")); err != nil {
+		t.Fatal(err)
+	}
+
+	h.Add("BTCUSDT1h", bars[2:])
+	h.Add("BTCUSDT1h", bars[:2])
+	if err := h.Correct("BTCUSDT1h", makeBars(12, time.Hour, 50)[5:6]); err != nil {
+		t.Fatal(err)
+	}
+	if err := h.ImportDir(src); err != nil {
+		t.Fatal(err)
+	}
+
+	if files, _ := os.ReadDir(dataDir()); len(files) != 0 {
+		t.Fatalf("datadir has %d files, want none", len(files))
+	}
+	if len(h.Bars("BTCUSDT1h")) != 12 || len(h.Bars("ETHUSDT1h")) != 10 {
+		t.Fatal("bars not kept in memory")
+	}
+}
+
+func TestCorrectStoresHistory(t *testing.T) {
+	h := newTestHistory(t)
+	// loading does not save, so the file has none of the bars
+	h.Add("BTCUSDT1h", makeBars(10, time.Hour))
+	if err := h.Correct("BTCUSDT1h", makeBars(10, time.Hour, 50)[5:6]); err != nil {
+		t.Fatal(err)
+	}
+	stored, err := ReadBars("BTCUSDT1h")
+	if err != nil || len(stored) != 10 || stored[5].Close != 50 {
+		t.Fatalf("stored %d bars (%v), want 10 with the corrected one", len(stored), err)
+	}
+
+	// a broken file is not overwritten
+	if err := os.WriteFile(barsFile("BTCUSDT1h"), []byte("{"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	if err := h.Correct("BTCUSDT1h", makeBars(10, time.Hour, 60)[5:6]); err == nil {
+		t.Fatal("want error for broken file")
+	}
+	if h.Bars("BTCUSDT1h")[5].Close != 50 {
+		t.Fatal("failed correction changed history")
+	}
+}
+
+func TestFilterLiquid(t *testing.T) {
+	h := newTestHistory(t)
+	h.SetPersist(false)
+	h.Add("BTCUSDT1h", makeBars(5, time.Hour))
+	h.Add("DUSTUSDT1h", makeBars(5, time.Hour, 0.01))
+
+	h.FilterLiquid(100)
+	if len(h.Bars("BTCUSDT1h")) == 0 || len(h.Bars("DUSTUSDT1h")) != 0 {
+		t.Fatalf("got symbols %v, want only BTCUSDT1h", symbols(h.Map()))
+	}
+}
+
+// flakyDownloader fails the first fails calls
+type flakyDownloader struct {
+	fails, calls int
+}
+
+func (d *flakyDownloader) GetKlines(pair, timeframe string, limit int) (Bars, error) {
+	d.calls++
+	if d.calls <= d.fails {
+		return nil, fmt.Errorf("call %d failed", d.calls)
+	}
+	return makeBars(limit, time.Hour), nil
+}
+
+func TestGetKlinesRetries(t *testing.T) {
+	h := newTestHistory(t)
+	h.SetRetry(3, time.Millisecond)
+
+	d := &flakyDownloader{fails: 2}
+	h.Downloader = d
+	if bars, err := h.getKlines("BTCUSDT", "1h", 5); err != nil || len(bars) != 5 || d.calls != 3 {
+		t.Fatalf("got %d bars (%v) after %d calls, want 5 after 3", len(bars), err, d.calls)
+	}
+
+	d = &flakyDownloader{fails: 5}
+	h.Downloader = d
+	if _, err := h.getKlines("BTCUSDT", "1h", 5); err == nil || d.calls != 3 {
+		t.Fatalf("got %v after %d calls, want error after 3", err, d.calls)
+	}
+}
@@ -2,6 +2,8 @@ package history
 
 import (
 	"math"
+	"sort"
+	"time"
 )
 
 // SMA on bars
@@ -32,7 +34,92 @@ func (bars Bars) LWMA(mode Price) float64 {
 	return -1.
 }
 
-// EMA on bars
+// SMAn is SMA of the last period bars, false if not enough bars
+func (bars Bars) SMAn(period int, mode Price) (float64, bool) {
+	if period <= 0 || period > len(bars) {
+		return 0, false
+	}
+
+	var sum float64
+	for i := 0; i < period; i++ {
+		sum += bars[i].Mode(mode)
+	}
+
+	return sum / float64(period), true
+}
+
+// LWMAn is LWMA of the last period bars, false if not enough bars
+func (bars Bars) LWMAn(period int, mode Price) (float64, bool) {
+	if period <= 0 || period > len(bars) {
+		return 0, false
+	}
+
+	var sum, weight float64
+	for i := period - 1; i >= 0; i-- {
+		weight += float64(period - i)
+		sum += bars[i].Mode(mode) * float64(period-i)
+	}
+
+	return sum / weight, true
+}
+
+// EMAn is EMA of period, seeded with SMA of the oldest period bars and smoothed
+// forward over the rest, false if not enough bars
+func (bars Bars) EMAn(period int, mode Price) (float64, bool) {
+	return bars.smooth(period, mode, 2/(float64(period)+1))
+}
+
+// EMAWilder is EMA of period with Wilder's smoothing (1/period), seeded like EMAn,
+// false if not enough bars
+func (bars Bars) EMAWilder(period int, mode Price) (float64, bool) {
+	return bars.smooth(period, mode, 1/float64(period))
+}
+
+// smooth bars forward from SMA of the oldest period bars with factor k
+func (bars Bars) smooth(period int, mode Price, k float64) (float64, bool) {
+	if period <= 0 || period > len(bars) {
+		return 0, false
+	}
+
+	seed := len(bars) - period
+	ema, _ := bars[seed:].SMAn(period, mode)
+	for i := seed - 1; i >= 0; i-- {
+		ema = bars[i].Mode(mode)*k + ema*(1-k)
+	}
+
+	return ema, true
+}
+
+// WMA is the linear weighted moving average of the last period bars,
+// returns -1 if not enough bars
+func (bars Bars) WMA(period int, mode Price) float64 {
+	if period <= 0 || period > len(bars) {
+		return -1.
+	}
+
+	return bars[:period].LWMA(mode)
+}
+
+// HMA is the Hull moving average WMA(2*WMA(period/2) - WMA(period)) over sqrt(period) bars,
+// returns -1 if not enough bars
+func (bars Bars) HMA(period int, mode Price) float64 {
+	sqrt := int(math.Sqrt(float64(period)))
+	if period < 2 || period+sqrt-1 > len(bars) {
+		return -1.
+	}
+
+	var sum, weight float64
+	for i := 0; i < sqrt; i++ {
+		raw := 2*bars[i:].WMA(period/2, mode) - bars[i:].WMA(period, mode)
+		weight += float64(sqrt - i)
+		sum += raw * float64(sqrt-i)
+	}
+
+	return sum / weight
+}
+
+// EMA on bars, with period and seed of all bars. smoothing changes with
+// the number of bars, use EMAn for EMA of a fixed period
 func (bars Bars) EMA(mode Price) float64 {
 	period := len(bars)
 	var last, k, sum float64
@@ -47,7 +134,31 @@ func (bars Bars) EMA(mode Price) float64 {
 	return sum
 }
 
-// ATR ..
+// ColorByTrend returns a color for each bar, "green" when close is above
+// the ema of emaPeriod bars, "red" when below and "gray" otherwise
+func (bars Bars) ColorByTrend(emaPeriod int) []string {
+	colors := make([]string, len(bars))
+
+	for i, b := range bars {
+		colors[i] = "gray"
+		if emaPeriod <= 0 || i+emaPeriod > len(bars) {
+			continue
+		}
+
+		ema := bars[i : i+emaPeriod].EMA(C)
+		if b.Close > ema {
+			colors[i] = "green"
+		}
+		if b.Close < ema {
+			colors[i] = "red"
+		}
+	}
+
+	return colors
+}
+
+// ATR is the average high-low range of all bars, gaps between bars are ignored.
+// See ATRWilder for average true range
 func (bars Bars) ATR() float64 {
 	var sum float64
 
@@ -58,6 +169,183 @@ func (bars Bars) ATR() float64 {
 	return sum / float64(len(bars))
 }
 
+// TrueRange of the last bar, the range including a gap from previous close
+func (bars Bars) TrueRange() float64 {
+	if len(bars) == 0 {
+		return 0
+	}
+	if len(bars) == 1 {
+		return bars[0].Range()
+	}
+
+	return trueRange(bars[0], bars[1].Close)
+}
+
+// trueRange of bar b with previous close
+func trueRange(b Bar, prevClose float64) float64 {
+	return math.Max(b.Range(), math.Max(math.Abs(b.High-prevClose), math.Abs(b.Low-prevClose)))
+}
+
+// ATRWilder is the average true range with Wilder's smoothing over all bars,
+// seeded with the mean true range of the oldest period bars.
+// Needs at least period+1 bars or returns 0
+func (bars Bars) ATRWilder(period int) float64 {
+	if period <= 0 || period+1 > len(bars) {
+		return 0
+	}
+
+	var atr float64
+	last := len(bars) - 1
+	for i := last - 1; i >= last-period; i-- {
+		atr += trueRange(bars[i], bars[i+1].Close)
+	}
+	atr /= float64(period)
+
+	for i := last - period - 1; i >= 0; i-- {
+		atr = (atr*float64(period-1) + trueRange(bars[i], bars[i+1].Close)) / float64(period)
+	}
+
+	return atr
+}
+
+// ATRPercent returns ATRWilder of period in percent of the last close,
+// to compare volatility across symbols of any price
+func (bars Bars) ATRPercent(period int) float64 {
+	if len(bars) == 0 || bars[0].Close == 0 {
+		return 0
+	}
+	return 100 * bars.ATRWilder(period) / bars[0].Close
+}
+
+// ROC is the rate of change in percent of the last close from the close period bars ago,
+// NaN if not enough bars
+func (bars Bars) ROC(period int) float64 {
+	if period <= 0 || period >= len(bars) || bars[period].Close == 0 {
+		return math.NaN()
+	}
+	return (bars[0].Close/bars[period].Close - 1) * 100
+}
+
+// Momentum is the last close minus the close period bars ago, NaN if not enough bars
+func (bars Bars) Momentum(period int) float64 {
+	if period <= 0 || period >= len(bars) {
+		return math.NaN()
+	}
+	return bars[0].Close - bars[period].Close
+}
+
+// Aroon measures bars since the highest high (up) and lowest low (down) of the last period+1 bars,
+// 100 when it is the last bar and 0 when it is period bars ago. osc is up - down.
+// returns zeros if not enough bars
+func (bars Bars) Aroon(period int) (up, down, osc float64) {
+	if period <= 0 || period >= len(bars) {
+		return 0, 0, 0
+	}
+
+	window := bars[:period+1]
+	up = 100 * float64(period-window.HighestIdx(H)) / float64(period)
+	down = 100 * float64(period-window.LowestIdx(L)) / float64(period)
+	return up, down, up - down
+}
+
+// VWAP is the volume weighted average typical price (high+low+close)/3 of all bars,
+// 0 if there is no volume
+func (bars Bars) VWAP() float64 {
+	var pv, volume float64
+	for _, b := range bars {
+		pv += (b.High + b.Low + b.Close) / 3 * b.Volume
+		volume += b.Volume
+	}
+	if volume == 0 {
+		return 0
+	}
+	return pv / volume
+}
+
+// AnchoredVWAP is VWAP of bars from the bar at or after anchor to the last bar,
+// 0 if anchor is after the last bar
+func (bars Bars) AnchoredVWAP(anchor time.Time) float64 {
+	if len(bars) == 0 || anchor.After(bars[0].Time) {
+		return 0
+	}
+	// bars are newest first, find the first bar before anchor
+	n := sort.Search(len(bars), func(i int) bool { return bars[i].Time.Before(anchor) })
+	return bars[:n].VWAP()
+}
+
+// DollarVolume returns the average close*volume of the last period bars,
+// period <= 0 or larger then bars uses all bars
+func (bars Bars) DollarVolume(period int) float64 {
+	if period <= 0 || period > len(bars) {
+		period = len(bars)
+	}
+	if period == 0 {
+		return 0
+	}
+
+	var sum float64
+	for _, b := range bars[:period] {
+		sum += b.Close * b.Volume
+	}
+
+	return sum / float64(period)
+}
+
+// PriceVolume is the volume traded at a price level
+type PriceVolume struct {
+	Price  float64
+	Volume float64
+}
+
+// VolumeProfile splits the price range of bars into bins levels (lowest first) and sums
+// the volume traded at each level, spreading each bar volume evenly over its range.
+// poc is the point of control, the level with the highest volume
+func (bars Bars) VolumeProfile(bins int) (profile []PriceVolume, poc PriceVolume) {
+	if bins <= 0 || len(bars) == 0 {
+		return []PriceVolume{}, PriceVolume{}
+	}
+
+	low, high := bars.Lowest(L), bars.Highest(H)
+	step := (high - low) / float64(bins)
+
+	profile = make([]PriceVolume, bins)
+	for i := range profile {
+		profile[i].Price = low + step*(float64(i)+0.5)
+	}
+
+	level := func(price float64) int {
+		if step == 0 {
+			return 0
+		}
+		n := int((price - low) / step)
+		if n >= bins {
+			n = bins - 1
+		}
+		return n
+	}
+
+	for _, b := range bars {
+		first, last := level(b.Low), level(b.High)
+		if first == last {
+			profile[first].Volume += b.Volume
+			continue
+		}
+		// volume by overlap of bar range with each level
+		for n := first; n <= last; n++ {
+			lo := math.Max(b.Low, low+step*float64(n))
+			hi := math.Min(b.High, low+step*float64(n+1))
+			profile[n].Volume += b.Volume * (hi - lo) / b.Range()
+		}
+	}
+
+	for _, pv := range profile {
+		if pv.Volume > poc.Volume {
+			poc = pv
+		}
+	}
+	return profile, poc
+}
+
 // Standard Deviation
 func (bars Bars) StDev(mode Price) float64 {
 	var v float64
@@ -71,11 +359,191 @@ func (bars Bars) StDev(mode Price) float64 {
 	return math.Sqrt(v / 20)
 }
 
+// Correlation returns the pearson correlation coefficient of returns of bars and other,
+// only bars with common timestamps are used. returns 0 if there are less than 3 common bars
+func (bars Bars) Correlation(other Bars, mode Price) float64 {
+	x, y := commonReturns(bars, other, mode)
+	if len(x) < 2 {
+		return 0
+	}
+
+	var mx, my float64
+	for i := range x {
+		mx += x[i]
+		my += y[i]
+	}
+	mx /= float64(len(x))
+	my /= float64(len(y))
+
+	var cov, vx, vy float64
+	for i := range x {
+		cov += (x[i] - mx) * (y[i] - my)
+		vx += (x[i] - mx) * (x[i] - mx)
+		vy += (y[i] - my) * (y[i] - my)
+	}
+	if vx == 0 || vy == 0 {
+		return 0
+	}
+
+	return cov / math.Sqrt(vx*vy)
+}
+
+// Beta returns the regression slope of returns of bars against returns of benchmark,
+// over their common timestamps
+func (bars Bars) Beta(benchmark Bars) float64 {
+	x, y := commonReturns(benchmark, bars, C)
+	if len(x) < 2 {
+		return 0
+	}
+
+	var mx, my float64
+	for i := range x {
+		mx += x[i]
+		my += y[i]
+	}
+	mx /= float64(len(x))
+	my /= float64(len(y))
+
+	var cov, vx float64
+	for i := range x {
+		cov += (x[i] - mx) * (y[i] - my)
+		vx += (x[i] - mx) * (x[i] - mx)
+	}
+	if vx == 0 {
+		return 0
+	}
+
+	return cov / vx
+}
+
+// Spread returns close of bars minus close of other over their common timestamps (newest first)
+func (bars Bars) Spread(other Bars) []float64 {
+	spread := []float64{}
+	for i, j := 0, 0; i < len(bars) && j < len(other); {
+		switch {
+		case bars[i].Time.After(other[j].Time):
+			i++
+		case other[j].Time.After(bars[i].Time):
+			j++
+		default:
+			spread = append(spread, bars[i].Close-other[j].Close)
+			i++
+			j++
+		}
+	}
+	return spread
+}
+
+// commonReturns returns aligned returns of a and b over their common timestamps,
+// both must be sorted (newest first)
+func commonReturns(a, b Bars, mode Price) (x, y []float64) {
+	var pa, pb []float64
+	for i, j := 0, 0; i < len(a) && j < len(b); {
+		switch {
+		case a[i].Time.After(b[j].Time):
+			i++
+		case b[j].Time.After(a[i].Time):
+			j++
+		default:
+			pa = append(pa, a[i].Mode(mode))
+			pb = append(pb, b[j].Mode(mode))
+			i++
+			j++
+		}
+	}
+
+	for i := 0; i < len(pa)-1; i++ {
+		if pa[i+1] == 0 || pb[i+1] == 0 {
+			continue
+		}
+		x = append(x, pa[i]/pa[i+1]-1)
+		y = append(y, pb[i]/pb[i+1]-1)
+	}
+	return x, y
+}
+
+// QuantileBands returns the lower and upper quantiles (0..1) of closes
+// over the last period bars
+func (bars Bars) QuantileBands(period int, lower, upper float64) (low, high float64) {
+	if period <= 0 || period > len(bars) {
+		period = len(bars)
+	}
+	if period == 0 {
+		return 0, 0
+	}
+
+	closes := make([]float64, period)
+	for i, b := range bars[:period] {
+		closes[i] = b.Close
+	}
+	sort.Float64s(closes)
+
+	return quantile(closes, lower), quantile(closes, upper)
+}
+
+// quantile of sorted values with linear interpolation
+func quantile(sorted []float64, q float64) float64 {
+	q = math.Max(0, math.Min(1, q))
+	pos := q * float64(len(sorted)-1)
+	i := int(pos)
+	if i+1 >= len(sorted) {
+		return sorted[len(sorted)-1]
+	}
+
+	return sorted[i] + (sorted[i+1]-sorted[i])*(pos-float64(i))
+}
+
 // Range ..
 func (bars Bars) Range() float64 {
 	return bars.Highest(H) - bars.Lowest(L)
 }
 
+// Donchian returns highest high, lowest low and their mid over the last period bars
+func (bars Bars) Donchian(period int) (upper, lower, mid float64) {
+	if period <= 0 || period > len(bars) {
+		return 0, 0, 0
+	}
+
+	upper = bars[0:period].Highest(H)
+	lower = bars[0:period].Lowest(L)
+	return upper, lower, (upper + lower) / 2
+}
+
+// PivotPoints returns classic floor pivots from bars[0], witch is treated
+// as the previous (completed) period since history never holds the forming bar
+func (bars Bars) PivotPoints() (p, r1, r2, r3, s1, s2, s3 float64) {
+	if len(bars) == 0 {
+		return
+	}
+
+	b := bars[0]
+	p = b.HLC3()
+	r1 = 2*p - b.Low
+	s1 = 2*p - b.High
+	r2 = p + b.Range()
+	s2 = p - b.Range()
+	r3 = b.High + 2*(p-b.Low)
+	s3 = b.Low - 2*(b.High-p)
+	return
+}
+
+// PivotFibonacci returns fibonacci pivots from bars[0], see PivotPoints
+func (bars Bars) PivotFibonacci() (p, r1, r2, r3, s1, s2, s3 float64) {
+	if len(bars) == 0 {
+		return
+	}
+
+	b := bars[0]
+	p = b.HLC3()
+	r1 = p + 0.382*b.Range()
+	s1 = p - 0.382*b.Range()
+	r2 = p + 0.618*b.Range()
+	s2 = p - 0.618*b.Range()
+	r3 = p + b.Range()
+	s3 = p - b.Range()
+	return
+}
+
 // Highest ..
 func (bars Bars) Highest(mode Price) float64 {
 	highest := -1.
@@ -150,6 +618,153 @@ func (bars Bars) LastBearIdx() int {
 	return -1
 }
 
+// MaxConsecutiveUp returns the longest run of closes higher then previous close
+func (bars Bars) MaxConsecutiveUp() int {
+	var max, run int
+	for i := len(bars) - 2; i >= 0; i-- {
+		if bars[i].Close > bars[i+1].Close {
+			run++
+		} else {
+			run = 0
+		}
+		if run > max {
+			max = run
+		}
+	}
+
+	return max
+}
+
+// MaxConsecutiveDown returns the longest run of closes lower then previous close
+func (bars Bars) MaxConsecutiveDown() int {
+	var max, run int
+	for i := len(bars) - 2; i >= 0; i-- {
+		if bars[i].Close < bars[i+1].Close {
+			run++
+		} else {
+			run = 0
+		}
+		if run > max {
+			max = run
+		}
+	}
+
+	return max
+}
+
+// CurrentStreak returns the run of closes up (positive) or down (negative)
+// ending at the last bar
+func (bars Bars) CurrentStreak() int {
+	var streak int
+	for i := 0; i < len(bars)-1; i++ {
+		switch {
+		case bars[i].Close > bars[i+1].Close && streak >= 0:
+			streak++
+		case bars[i].Close < bars[i+1].Close && streak <= 0:
+			streak--
+		default:
+			return streak
+		}
+	}
+
+	return streak
+}
+
+// MaxDrawdown returns the largest drop in percent from a running peak,
+// with the time of that peak and of the trough
+func (bars Bars) MaxDrawdown(mode Price) (dd float64, peak, trough time.Time) {
+	if len(bars) == 0 {
+		return 0, time.Time{}, time.Time{}
+	}
+
+	high := bars.FirstBar()
+	for i := len(bars) - 1; i >= 0; i-- {
+		if bars[i].Mode(mode) > high.Mode(mode) {
+			high = bars[i]
+		}
+		if high.Mode(mode) == 0 {
+			continue
+		}
+		if d := 100 * (1 - bars[i].Mode(mode)/high.Mode(mode)); d > dd {
+			dd, peak, trough = d, high.Time, bars[i].Time
+		}
+	}
+
+	return dd, peak, trough
+}
+
+// Underwater returns the percentage below the running peak at each bar (newest first)
+func (bars Bars) Underwater(mode Price) []float64 {
+	under := make([]float64, len(bars))
+
+	var high float64
+	for i := len(bars) - 1; i >= 0; i-- {
+		high = math.Max(high, bars[i].Mode(mode))
+		if high != 0 {
+			under[i] = 100 * (1 - bars[i].Mode(mode)/high)
+		}
+	}
+
+	return under
+}
+
+// ZigZagPoint is a pivot high or low of ZigZag
+type ZigZagPoint struct {
+	Index int // index in bars
+	Time  time.Time
+	Price float64
+	Peak  bool // true for a high, false for a low
+}
+
+// ZigZag returns alternating pivot highs and lows in time order (oldest first),
+// where price reversed by at least deviation percent. the last, still open,
+// pivot is not included until price reverses from it
+func (bars Bars) ZigZag(deviation float64) []ZigZagPoint {
+	var points []ZigZagPoint
+	if len(bars) < 2 || deviation <= 0 {
+		return points
+	}
+
+	last := len(bars) - 1
+	hi, lo := last, last
+	trend := 0 // 1 up, -1 down, 0 not known yet
+
+	for i := last - 1; i >= 0; i-- {
+		switch trend {
+		case 0:
+			if bars[i].High > bars[hi].High {
+				hi = i
+			}
+			if bars[i].Low < bars[lo].Low {
+				lo = i
+			}
+			if bars[i].High >= bars[lo].Low*(1+deviation/100) {
+				points = append(points, ZigZagPoint{lo, bars[lo].Time, bars[lo].Low, false})
+				trend, hi = 1, i
+			} else if bars[i].Low <= bars[hi].High*(1-deviation/100) {
+				points = append(points, ZigZagPoint{hi, bars[hi].Time, bars[hi].High, true})
+				trend, lo = -1, i
+			}
+		case 1:
+			if bars[i].High > bars[hi].High {
+				hi = i
+			} else if bars[i].Low <= bars[hi].High*(1-deviation/100) {
+				points = append(points, ZigZagPoint{hi, bars[hi].Time, bars[hi].High, true})
+				trend, lo = -1, i
+			}
+		case -1:
+			if bars[i].Low < bars[lo].Low {
+				lo = i
+			} else if bars[i].High >= bars[lo].Low*(1+deviation/100) {
+				points = append(points, ZigZagPoint{lo, bars[lo].Time, bars[lo].Low, false})
+				trend, hi = 1, i
+			}
+		}
+	}
+
+	return points
+}
+
 // WithinRange
 func WithinRange(src, dest, r float64) bool {
 	return math.Abs(src-dest) < r
@@ -162,6 +777,9 @@ func CalcPercentage(n, total float64) float64 {
 
 // IsEngulfBuy
 func (bars Bars) IsEngulfBuy() bool {
+	if len(bars) < 2 {
+		return false
+	}
 	o0 := bars[0].Open
 	o1 := bars[1].Open
 	c0 := bars[0].Close
@@ -176,6 +794,9 @@ func (bars Bars) IsEngulfBuy() bool {
 
 // IsEngulfSell
 func (bars Bars) IsEngulfSell() bool {
+	if len(bars) < 2 {
+		return false
+	}
 	o0 := bars[0].Open
 	o1 := bars[1].Open
 	c0 := bars[0].Close
@@ -188,6 +809,85 @@ func (bars Bars) IsEngulfSell() bool {
 	return false
 }
 
+// IsDoji last bar body is less then 10% of its range
+func (bars Bars) IsDoji() bool {
+	if len(bars) < 1 || bars[0].Range() == 0 {
+		return false
+	}
+	return bars[0].Body() <= bars[0].Range()/10
+}
+
+// IsHammer last bar lower wick is more then twice the body and upper wick is smaller then body
+func (bars Bars) IsHammer() bool {
+	if len(bars) < 1 || bars[0].Body() == 0 {
+		return false
+	}
+	b := bars[0]
+	return b.WickDn() > 2*b.Body() && b.WickUp() < b.Body()
+}
+
+// IsShootingStar last bar upper wick is more then twice the body and lower wick is smaller then body
+func (bars Bars) IsShootingStar() bool {
+	if len(bars) < 1 || bars[0].Body() == 0 {
+		return false
+	}
+	b := bars[0]
+	return b.WickUp() > 2*b.Body() && b.WickDn() < b.Body()
+}
+
+// IsMorningStar bear bar, small bar below its close, then bull bar closing above its body mid
+func (bars Bars) IsMorningStar() bool {
+	if len(bars) < 3 {
+		return false
+	}
+	first, star, last := bars[2], bars[1], bars[0]
+	return first.Bear() && star.Body() < first.Body()/2 && star.BodyHigh() <= first.Close &&
+		last.Bull() && last.Close > (first.Open+first.Close)/2
+}
+
+// IsEveningStar bull bar, small bar above its close, then bear bar closing below its body mid
+func (bars Bars) IsEveningStar() bool {
+	if len(bars) < 3 {
+		return false
+	}
+	first, star, last := bars[2], bars[1], bars[0]
+	return first.Bull() && star.Body() < first.Body()/2 && star.BodyLow() >= first.Close &&
+		last.Bear() && last.Close < (first.Open+first.Close)/2
+}
+
+// IsHarami last bar body is inside the previous bar body, in the opposite direction
+func (bars Bars) IsHarami() bool {
+	if len(bars) < 2 {
+		return false
+	}
+	b0, b1 := bars[0], bars[1]
+	return (b0.Bull() && b1.Bear() || b0.Bear() && b1.Bull()) &&
+		b0.BodyHigh() < b1.BodyHigh() && b0.BodyLow() > b1.BodyLow()
+}
+
+// DetectPatterns returns names of all candlestick patterns found on the last bars
+func (bars Bars) DetectPatterns() []string {
+	patterns := []string{}
+	for _, p := range []struct {
+		name string
+		is   func() bool
+	}{
+		{"Doji", bars.IsDoji},
+		{"Hammer", bars.IsHammer},
+		{"ShootingStar", bars.IsShootingStar},
+		{"MorningStar", bars.IsMorningStar},
+		{"EveningStar", bars.IsEveningStar},
+		{"Harami", bars.IsHarami},
+		{"EngulfBuy", bars.IsEngulfBuy},
+		{"EngulfSell", bars.IsEngulfSell},
+	} {
+		if p.is() {
+			patterns = append(patterns, p.name)
+		}
+	}
+	return patterns
+}
+
 // TD Sequential 9
 func (bars Bars) TD() int {
 	var uc []int = make([]int, len(bars))
@@ -0,0 +1,170 @@
+package history
+
+import (
+	"math"
+	"testing"
+	"time"
+)
+
+// scale returns bars with all prices multiplied by k
+func scale(bars Bars, k float64) Bars {
+	scaled := make(Bars, len(bars))
+	for i, b := range bars {
+		b.Open, b.High, b.Low, b.Close = b.Open*k, b.High*k, b.Low*k, b.Close*k
+		scaled[i] = b
+	}
+	return scaled
+}
+
+func TestATRPercent(t *testing.T) {
+	// range of 2 without gaps at close 100
+	bars := makeBars(30, time.Hour, 100)
+	if got := bars.ATRPercent(14); math.Abs(got-2) > 1e-9 {
+		t.Fatalf("ATRPercent %v, want 2", got)
+	}
+	// the same bars at ten times the price have the same percent
+	bars = makeBars(30, time.Hour, 100, 103, 99, 101)
+	if a, b := bars.ATRPercent(14), scale(bars, 10).ATRPercent(14); math.Abs(a-b) > 1e-9 {
+		t.Fatalf("ATRPercent %v and %v of scaled bars differ", a, b)
+	}
+}
+
+// candles returns bars of ohlc values given newest first
+func candles(ohlc ...[4]float64) Bars {
+	bars := make(Bars, len(ohlc))
+	for i, v := range ohlc {
+		bars[i] = Bar{Time: start.Add(time.Duration(len(ohlc)-i) * time.Hour), Open: v[0], High: v[1], Low: v[2], Close: v[3]}
+	}
+	return bars
+}
+
+func TestCandlestickPatterns(t *testing.T) {
+	for _, tc := range []struct {
+		pattern string
+		bars    Bars
+	}{
+		{"Doji", candles([4]float64{100, 105, 95, 100.5})},
+		{"Hammer", candles([4]float64{100, 101.5, 97, 101})},
+		{"ShootingStar", candles([4]float64{101, 104, 99.5, 100})},
+		{"MorningStar", candles([4]float64{96, 104.5, 95.5, 104}, [4]float64{95, 96, 94, 95.5}, [4]float64{105, 105.5, 95.5, 96})},
+		{"EveningStar", candles([4]float64{105, 105.5, 96.5, 97}, [4]float64{105.5, 106.5, 105, 106}, [4]float64{96, 105.5, 95.5, 105})},
+		{"Harami", candles([4]float64{98, 101.5, 97.5, 101}, [4]float64{105, 105.5, 94.5, 95})},
+	} {
+		found := false
+		for _, p := range tc.bars.DetectPatterns() {
+			found = found || p == tc.pattern
+		}
+		if !found {
+			t.Errorf("%s not detected, got %v", tc.pattern, tc.bars.DetectPatterns())
+		}
+	}
+
+	// a plain bull bar has no pattern
+	if got := candles([4]float64{100, 104.5, 99.5, 104}).DetectPatterns(); len(got) != 0 {
+		t.Errorf("plain bar has patterns %v", got)
+	}
+}
+
+func TestEMASeededWithSMA(t *testing.T) {
+	// closes 1 to 5, seed is SMA of 1, 2, 3
+	bars := makeBars(5, time.Hour, 1, 2, 3, 4, 5)
+
+	// k = 2/(3+1): 2 -> 3 -> 4
+	if ema, ok := bars.EMAn(3, C); !ok || math.Abs(ema-4) > 1e-9 {
+		t.Fatalf("EMAn %v %v, want 4", ema, ok)
+	}
+	// k = 1/3: 2 -> 8/3 -> 31/9
+	if ema, ok := bars.EMAWilder(3, C); !ok || math.Abs(ema-31./9) > 1e-9 {
+		t.Fatalf("EMAWilder %v %v, want %v", ema, ok, 31./9)
+	}
+	if _, ok := bars.EMAn(6, C); ok {
+		t.Fatal("EMAn of more bars then given is ok")
+	}
+}
+
+func TestAroon(t *testing.T) {
+	// newest first closes 8, 20, 5, 1, 10: high 1 bar ago, low 3 bars ago
+	bars := makeBars(5, time.Hour, 10, 1, 5, 20, 8)
+	if up, down, osc := bars.Aroon(4); up != 75 || down != 25 || osc != 50 {
+		t.Fatalf("Aroon %v %v %v, want 75 25 50", up, down, osc)
+	}
+	if up, down, _ := makeBars(20, time.Hour).Aroon(14); up != 100 || down != 0 {
+		t.Fatalf("Aroon of rising bars %v %v, want 100 0", up, down)
+	}
+	if up, down, osc := bars.Aroon(5); up != 0 || down != 0 || osc != 0 {
+		t.Fatal("Aroon without enough bars is not zero")
+	}
+}
+
+func TestDollarVolume(t *testing.T) {
+	// closes 100 to 104 (oldest first) with volume 10
+	bars := makeBars(5, time.Hour)
+	if got := bars.DollarVolume(2); got != (1040+1030)/2. {
+		t.Fatalf("DollarVolume(2) %v, want 1035", got)
+	}
+	if got := bars.DollarVolume(0); got != 1020 {
+		t.Fatalf("DollarVolume(0) %v, want 1020", got)
+	}
+}
+
+func TestColorByTrend(t *testing.T) {
+	// rising then falling closes, oldest first
+	bars := makeBars(8, time.Hour, 1, 2, 3, 4, 5, 4, 3, 2)
+	colors := bars.ColorByTrend(3)
+	want := []string{"red", "red", "red", "green", "green", "green", "gray", "gray"}
+	if len(colors) != len(want) {
+		t.Fatalf("got %d colors, want %d", len(colors), len(want))
+	}
+	for i := range want {
+		if colors[i] != want[i] {
+			t.Fatalf("colors %v, want %v", colors, want)
+		}
+	}
+}
+
+func TestConsecutiveRuns(t *testing.T) {
+	bars := makeBars(9, time.Hour, 1, 2, 3, 4, 3, 2, 2, 1, 0)
+	if got := bars.MaxConsecutiveUp(); got != 3 {
+		t.Errorf("MaxConsecutiveUp %d, want 3", got)
+	}
+	// an equal close ends a run
+	if got := bars.MaxConsecutiveDown(); got != 2 {
+		t.Errorf("MaxConsecutiveDown %d, want 2", got)
+	}
+	if got := bars.CurrentStreak(); got != -2 {
+		t.Errorf("CurrentStreak %d, want -2", got)
+	}
+	if got := makeBars(5, time.Hour).CurrentStreak(); got != 4 {
+		t.Errorf("CurrentStreak of rising bars %d, want 4", got)
+	}
+}
+
+func TestQuantileBands(t *testing.T) {
+	// closes 5 to 1 newest first, older bars are out of the period
+	bars := makeBars(8, time.Hour, 100, 100, 100, 1, 2, 3, 4, 5)
+	if low, high := bars.QuantileBands(5, 0.25, 0.75); low != 2 || high != 4 {
+		t.Fatalf("bands %v %v, want 2 and 4", low, high)
+	}
+	// interpolated between sorted closes
+	if low, high := bars.QuantileBands(5, 0.1, 1); math.Abs(low-1.4) > 1e-9 || high != 5 {
+		t.Fatalf("bands %v %v, want 1.4 and 5", low, high)
+	}
+}
+
+func TestBetaAndSpread(t *testing.T) {
+	// returns +10%, -10%, +10% and twice that
+	benchmark := makeBars(4, time.Hour, 100, 110, 99, 108.9)
+	bars := makeBars(4, time.Hour, 100, 120, 96, 115.2)
+	if got := bars.Beta(benchmark); math.Abs(got-2) > 1e-9 {
+		t.Fatalf("Beta %v, want 2", got)
+	}
+	if got := benchmark.Beta(benchmark); math.Abs(got-1) > 1e-9 {
+		t.Fatalf("Beta with itself %v, want 1", got)
+	}
+
+	// only the 2 newest timestamps are common
+	spread := bars.Spread(benchmark[:2])
+	if len(spread) != 2 || math.Abs(spread[0]-(115.2-108.9)) > 1e-9 || math.Abs(spread[1]-(96-99)) > 1e-9 {
+		t.Fatalf("Spread %v", spread)
+	}
+}
@@ -0,0 +1,68 @@
+package history
+
+import (
+	"math"
+	"strconv"
+	"strings"
+)
+
+// SymbolInfo holds exchange trading rules of a symbol
+type SymbolInfo struct {
+	TickSize    float64 // price step
+	StepSize    float64 // quantity step
+	MinNotional float64 // minimum price*quantity of an order
+
+	PricePrecision int
+	QtyPrecision   int
+}
+
+// RoundPrice rounds price to the nearest tick size
+func (info SymbolInfo) RoundPrice(price float64) float64 {
+	if info.TickSize <= 0 {
+		return price
+	}
+	return roundTo(math.Round(price/info.TickSize)*info.TickSize, info.TickSize)
+}
+
+// RoundQty rounds quantity down to step size, so it never exceeds qty
+func (info SymbolInfo) RoundQty(qty float64) float64 {
+	if info.StepSize <= 0 {
+		return qty
+	}
+	return roundTo(math.Floor(qty/info.StepSize+1e-9)*info.StepSize, info.StepSize)
+}
+
+// roundTo removes float noise by rounding v to the decimals of step
+func roundTo(v, step float64) float64 {
+	s := strconv.FormatFloat(step, 'f', -1, 64)
+	decimals := 0
+	if i := strings.IndexByte(s, '.'); i >= 0 {
+		decimals = len(s) - i - 1
+	}
+	pow := math.Pow(10, float64(decimals))
+	return math.Round(v*pow) / pow
+}
+
+// SetInfo stores trading rules of a symbol or pair
+func (h *History) SetInfo(symbol string, info SymbolInfo) {
+	h.Lock()
+	defer h.Unlock()
+
+	if h.info == nil {
+		h.info = make(map[string]SymbolInfo)
+	}
+	h.info[symbol] = info
+}
+
+// GetInfo returns trading rules of symbol, or of its pair if symbol has none
+func (h *History) GetInfo(symbol string) (SymbolInfo, bool) {
+	h.RLock()
+	defer h.RUnlock()
+
+	if info, ok := h.info[symbol]; ok {
+		return info, true
+	}
+	pair, _ := SplitSymbol(symbol)
+	info, ok := h.info[pair]
+	return info, ok
+}
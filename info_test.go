@@ -0,0 +1,29 @@
+package history
+
+import "testing"
+
+func TestRoundPrice(t *testing.T) {
+	info := SymbolInfo{TickSize: 0.01, StepSize: 0.001}
+	for _, tc := range []struct{ price, want float64 }{
+		{1.006, 1.01},
+		{1.004, 1},
+		{0.1 + 0.2, 0.3},
+		{123.456, 123.46},
+	} {
+		if got := info.RoundPrice(tc.price); got != tc.want {
+			t.Errorf("RoundPrice(%v) = %v, want %v", tc.price, got, tc.want)
+		}
+	}
+	if got := info.RoundQty(1.2349); got != 1.234 {
+		t.Errorf("RoundQty(1.2349) = %v, want 1.234", got)
+	}
+}
+
+func TestGetInfoFallsBackToPair(t *testing.T) {
+	h := newTestHistory(t)
+	h.SetInfo("BTCUSDT", SymbolInfo{TickSize: 0.01})
+
+	if info, ok := h.GetInfo("BTCUSDT1h"); !ok || info.TickSize != 0.01 {
+		t.Fatalf("got %v %v, want pair info", info, ok)
+	}
+}
@@ -0,0 +1,68 @@
+package history
+
+import (
+	"math/rand"
+	"sort"
+)
+
+// Percentiles of a distribution
+type Percentiles struct {
+	P5, P50, P95 float64
+}
+
+// MonteCarloResult of resampled trade sequences
+type MonteCarloResult struct {
+	Iterations  int
+	FinalEquity Percentiles
+	MaxDrawdown Percentiles // percent below the running peak
+}
+
+// Profits returns profit of every closed position in closing order
+func (p *Portfolio) Profits() []float64 {
+	profits := make([]float64, len(p.Closed))
+	for i, pos := range p.Closed {
+		profits[i] = pos.profit
+	}
+	return profits
+}
+
+// MonteCarlo draws iterations sequences of len(profits) trades from profits with
+// replacement (bootstrap), starting from balance, and returns percentiles of final
+// equity and max drawdown. a trade can be drawn many times or never, so both spread
+// with the luck of the trades. the same seed gives the same result
+func MonteCarlo(profits []float64, balance float64, iterations int, seed int64) MonteCarloResult {
+	result := MonteCarloResult{Iterations: iterations}
+	if iterations <= 0 || len(profits) == 0 {
+		return result
+	}
+
+	rnd := rand.New(rand.NewSource(seed))
+	trades := make([]float64, len(profits))
+
+	equity := make([]float64, iterations)
+	drawdown := make([]float64, iterations)
+	for n := 0; n < iterations; n++ {
+		for i := range trades {
+			trades[i] = profits[rnd.Intn(len(profits))]
+		}
+
+		eq, peak, dd := balance, balance, 0.
+		for _, profit := range trades {
+			eq += profit
+			if eq > peak {
+				peak = eq
+			}
+			if peak > 0 && 100*(1-eq/peak) > dd {
+				dd = 100 * (1 - eq/peak)
+			}
+		}
+		equity[n], drawdown[n] = eq, dd
+	}
+
+	sort.Float64s(equity)
+	sort.Float64s(drawdown)
+	result.FinalEquity = Percentiles{quantile(equity, 0.05), quantile(equity, 0.5), quantile(equity, 0.95)}
+	result.MaxDrawdown = Percentiles{quantile(drawdown, 0.05), quantile(drawdown, 0.5), quantile(drawdown, 0.95)}
+
+	return result
+}
@@ -0,0 +1,27 @@
+package history
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestMonteCarloResamplesTrades(t *testing.T) {
+	profits := []float64{50, -30, 20, -40, 10, 60, -20}
+	result := MonteCarlo(profits, 1000, 200, 7)
+
+	// drawing with replacement spreads final equity around the 1050 of all trades
+	fe := result.FinalEquity
+	if !(fe.P5 < fe.P50 && fe.P50 < fe.P95) || fe.P5 >= 1050 || fe.P95 <= 1050 {
+		t.Fatalf("final equity %v does not spread around 1050", fe)
+	}
+	if result.MaxDrawdown.P5 >= result.MaxDrawdown.P95 {
+		t.Fatalf("drawdown %v does not vary", result.MaxDrawdown)
+	}
+
+	if again := MonteCarlo(profits, 1000, 200, 7); !reflect.DeepEqual(again, result) {
+		t.Fatalf("same seed gave %v and %v", result, again)
+	}
+	if other := MonteCarlo(profits, 1000, 200, 8); reflect.DeepEqual(other, result) {
+		t.Fatal("different seeds gave the same result")
+	}
+}
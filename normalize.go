@@ -0,0 +1,155 @@
+package history
+
+import (
+	"math"
+)
+
+// NormMode is the scaling method of Normalize
+type NormMode int
+
+const (
+	MinMax NormMode = iota // MinMax scales to 0..1
+	ZScore                 // ZScore scales to mean 0 and standard deviation 1
+)
+
+// Scaler holds scaling parameters of each field, to inverse transform predictions
+type Scaler struct {
+	Mode NormMode
+	// A and B are min and max for MinMax, mean and standard deviation for ZScore
+	A, B map[Price]float64
+}
+
+// Scale value of field
+func (s Scaler) Scale(v float64, field Price) float64 {
+	a, b := s.A[field], s.B[field]
+	switch s.Mode {
+	case MinMax:
+		if b == a {
+			return 0
+		}
+		return (v - a) / (b - a)
+	case ZScore:
+		if b == 0 {
+			return 0
+		}
+		return (v - a) / b
+	default:
+		return v
+	}
+}
+
+// Inverse scaled value of field
+func (s Scaler) Inverse(v float64, field Price) float64 {
+	a, b := s.A[field], s.B[field]
+	switch s.Mode {
+	case MinMax:
+		return a + v*(b-a)
+	case ZScore:
+		return a + v*b
+	default:
+		return v
+	}
+}
+
+// Normalize returns bars with every price field and volume scaled over all bars,
+// and the Scaler to inverse transform values
+func (bars Bars) Normalize(mode NormMode) (Bars, Scaler) {
+	s := Scaler{Mode: mode, A: make(map[Price]float64), B: make(map[Price]float64)}
+	if len(bars) == 0 {
+		return Bars{}, s
+	}
+
+	for _, field := range []Price{O, H, L, C, V} {
+		switch mode {
+		case MinMax:
+			s.A[field] = bars.Lowest(field)
+			s.B[field] = bars.Highest(field)
+		case ZScore:
+			mean := bars.SMA(field)
+			var v float64
+			for _, b := range bars {
+				v += math.Pow(b.Mode(field)-mean, 2)
+			}
+			s.A[field] = mean
+			s.B[field] = math.Sqrt(v / float64(len(bars)))
+		}
+	}
+
+	norm := make(Bars, len(bars))
+	for i, b := range bars {
+		norm[i] = Bar{
+			Time:   b.Time,
+			Open:   s.Scale(b.Open, O),
+			High:   s.Scale(b.High, H),
+			Low:    s.Scale(b.Low, L),
+			Close:  s.Scale(b.Close, C),
+			Volume: s.Scale(b.Volume, V),
+		}
+	}
+
+	return norm, s
+}
+
+// Returns of closes in percent, aligned with bars (newest first) so
+// returns[i] is the change from bars[i+1] to bars[i]. Length is len(bars)-1
+func (bars Bars) Returns() []float64 {
+	if len(bars) < 2 {
+		return []float64{}
+	}
+
+	returns := make([]float64, len(bars)-1)
+	for i := range returns {
+		returns[i] = 100 * (bars[i].Close/bars[i+1].Close - 1)
+	}
+	return returns
+}
+
+// LogReturns of closes, aligned like Returns
+func (bars Bars) LogReturns() []float64 {
+	if len(bars) < 2 {
+		return []float64{}
+	}
+
+	returns := make([]float64, len(bars)-1)
+	for i := range returns {
+		returns[i] = math.Log(bars[i].Close / bars[i+1].Close)
+	}
+	return returns
+}
+
+// Columns returns bars as parallel arrays oldest first, times in unix seconds
+func (bars Bars) Columns() (times []int64, open, high, low, close, volume []float64) {
+	n := len(bars)
+	times = make([]int64, n)
+	open = make([]float64, n)
+	high = make([]float64, n)
+	low = make([]float64, n)
+	close = make([]float64, n)
+	volume = make([]float64, n)
+
+	for i := range bars {
+		b := bars[n-1-i]
+		times[i] = b.Time.Unix()
+		open[i] = b.Open
+		high[i] = b.High
+		low[i] = b.Low
+		close[i] = b.Close
+		volume[i] = b.Volume
+	}
+	return times, open, high, low, close, volume
+}
+
+// ExtraColumns returns quote volume and trades of bars as parallel arrays oldest first,
+// in the order of Columns
+func (bars Bars) ExtraColumns() (quoteVolume []float64, trades []int64) {
+	n := len(bars)
+	quoteVolume = make([]float64, n)
+	trades = make([]int64, n)
+
+	for i := range bars {
+		b := bars[n-1-i]
+		quoteVolume[i] = b.QuoteVolume
+		trades[i] = b.Trades
+	}
+	return quoteVolume, trades
+}
@@ -0,0 +1,134 @@
+package history
+
+import (
+	"errors"
+	"runtime"
+	"sort"
+	"sync"
+	"time"
+)
+
+// Optimizer runs a strategy over a grid of parameters and ranks the results.
+// every combination is scored with PortfolioTest
+type Optimizer struct {
+	// Factory creates a new strategy from a set of params
+	Factory func(params map[string]float64) Strategy
+	// Grid holds all values to test for each param
+	Grid map[string][]float64
+	// Metric scores the result of a PortfolioTest, higher is better.
+	// see FinalBalance and Sharpe
+	Metric func(*TestResult) float64
+	// Workers running tests in parallel, defaults to number of cpus
+	Workers int
+}
+
+// OptimizeResult holds the result of one param combination
+type OptimizeResult struct {
+	Params    map[string]float64
+	Events    Events
+	Portfolio *Portfolio
+	Score     float64
+	Err       error
+}
+
+// Run tests every param combination of the grid and returns
+// the best result and all results ranked by score.
+// like parallel Test, the clock of hist is not set while workers run
+func (o *Optimizer) Run(hist *History, start, end time.Time) (OptimizeResult, []OptimizeResult, error) {
+	if o.Factory == nil {
+		return OptimizeResult{}, nil, errors.New("factory is missing")
+	}
+	if o.Metric == nil {
+		return OptimizeResult{}, nil, errors.New("metric is missing")
+	}
+
+	combos := combinations(o.Grid)
+	if len(combos) == 0 {
+		return OptimizeResult{}, nil, errors.New("empty grid")
+	}
+
+	workers := o.Workers
+	if workers <= 0 {
+		workers = runtime.NumCPU()
+	}
+
+	jobs := make(chan int)
+	results := make([]OptimizeResult, len(combos))
+
+	var wg sync.WaitGroup
+	for w := 0; w < workers; w++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+
+			for n := range jobs {
+				params := combos[n]
+				result, err := hist.portfolioTest(o.Factory(params), start, end, nil)
+				results[n] = OptimizeResult{Params: params, Err: err}
+				if err == nil {
+					results[n].Events = result.Events
+					results[n].Portfolio = result.Portfolio
+					results[n].Score = o.Metric(result)
+				}
+			}
+		}()
+	}
+	for n := range combos {
+		jobs <- n
+	}
+	close(jobs)
+	wg.Wait()
+
+	// failed tests are ranked last
+	sort.SliceStable(results, func(i, j int) bool {
+		if (results[i].Err == nil) != (results[j].Err == nil) {
+			return results[i].Err == nil
+		}
+		return results[i].Score > results[j].Score
+	})
+
+	if results[0].Err != nil {
+		return results[0], results, results[0].Err
+	}
+	return results[0], results, nil
+}
+
+// FinalBalance metric scores the portfolio value at the end of the test
+func FinalBalance(r *TestResult) float64 {
+	return r.Portfolio.Value()
+}
+
+// Sharpe metric scores the per trade sharpe ratio of the portfolio
+func Sharpe(r *TestResult) float64 {
+	return r.Portfolio.Sharpe()
+}
+
+// combinations returns every combination of params in grid
+func combinations(grid map[string][]float64) []map[string]float64 {
+	keys := make([]string, 0, len(grid))
+	for key := range grid {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+
+	combos := []map[string]float64{{}}
+	for _, key := range keys {
+		var next []map[string]float64
+		for _, combo := range combos {
+			for _, v := range grid[key] {
+				params := make(map[string]float64, len(combo)+1)
+				for k, x := range combo {
+					params[k] = x
+				}
+				params[key] = v
+				next = append(next, params)
+			}
+		}
+		combos = next
+	}
+
+	if len(keys) == 0 {
+		return nil
+	}
+	return combos
+}
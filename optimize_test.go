@@ -0,0 +1,75 @@
+package history
+
+import (
+	"testing"
+	"time"
+)
+
+// holdFor buys on the fifth bar and closes after hold bars
+type holdFor struct {
+	hold int
+}
+
+func (s holdFor) Run(symbol string, bars Bars) (Event, bool) {
+	event := NewEvent(symbol)
+	event.Time = bars[0].Time
+	event.Price = bars[0].Close
+	event.Size = 1
+	switch len(bars) {
+	case 5:
+		event.Type = MARKET_BUY
+	case 5 + s.hold:
+		event.Type = CLOSE_BUY
+	default:
+		return Event{}, false
+	}
+	return event, true
+}
+
+func TestOptimizerFinalBalance(t *testing.T) {
+	h := newTestHistory(t)
+	h.SetPersist(false)
+	h.SetBalance(1000)
+	h.Add("BTCUSDT1h", makeBars(20, time.Hour))
+
+	o := &Optimizer{
+		Factory: func(params map[string]float64) Strategy { return holdFor{int(params["hold"])} },
+		Grid:    map[string][]float64{"hold": {2, 8}},
+		Metric:  FinalBalance,
+	}
+	best, all, err := o.Run(h, h.FirstTime(), h.LastTime())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(all) != 2 {
+		t.Fatalf("got %d results, want 2", len(all))
+	}
+	// closes rise by 1 every bar, so the longer hold wins
+	if best.Params["hold"] != 8 || best.Score != 1008 || all[1].Score != 1002 {
+		t.Fatalf("best %v score %v, other score %v", best.Params, best.Score, all[1].Score)
+	}
+	if best.Portfolio == nil || best.Portfolio.Stats.TotalTrades != 1 {
+		t.Fatalf("want portfolio with one trade, got %v", best.Portfolio)
+	}
+}
+
+func TestOptimizerLeavesClock(t *testing.T) {
+	h := newTestHistory(t)
+	h.SetPersist(false)
+	h.Add("BTCUSDT1h", makeBars(20, time.Hour))
+	clock := NewFakeClock(start)
+	h.SetClock(clock)
+
+	o := &Optimizer{
+		Factory: func(params map[string]float64) Strategy { return holdFor{int(params["hold"])} },
+		Grid:    map[string][]float64{"hold": {1, 2, 3, 4}},
+		Metric:  FinalBalance,
+		Workers: 4,
+	}
+	if _, _, err := o.Run(h, h.FirstTime(), h.LastTime()); err != nil {
+		t.Fatal(err)
+	}
+	if !clock.Now().Equal(start) {
+		t.Fatalf("clock moved to %v", clock.Now())
+	}
+}
@@ -0,0 +1,86 @@
+package parquet
+
+import (
+	"time"
+
+	"github.com/slicken/history"
+	"github.com/xitongsys/parquet-go-source/local"
+	pq "github.com/xitongsys/parquet-go/parquet"
+	"github.com/xitongsys/parquet-go/reader"
+	"github.com/xitongsys/parquet-go/writer"
+)
+
+// row is a parquet row of a bar
+type row struct {
+	Time   int64   `parquet:"name=time, type=INT64, convertedtype=TIMESTAMP_MILLIS"`
+	Open   float64 `parquet:"name=open, type=DOUBLE"`
+	High   float64 `parquet:"name=high, type=DOUBLE"`
+	Low    float64 `parquet:"name=low, type=DOUBLE"`
+	Close  float64 `parquet:"name=close, type=DOUBLE"`
+	Volume float64 `parquet:"name=volume, type=DOUBLE"`
+	// QuoteVolume and Trades are zero if the bars have none
+	QuoteVolume float64 `parquet:"name=quote_volume, type=DOUBLE"`
+	Trades      int64   `parquet:"name=trades, type=INT64"`
+}
+
+// Write saves bars to a snappy compressed parquet file, oldest bar first
+// with columns time (timestamp millis), open, high, low, close, volume, quote_volume, trades
+func Write(filename string, bars history.Bars) error {
+	fw, err := local.NewLocalFileWriter(filename)
+	if err != nil {
+		return err
+	}
+	defer fw.Close()
+
+	pw, err := writer.NewParquetWriter(fw, new(row), 1)
+	if err != nil {
+		return err
+	}
+	pw.CompressionType = pq.CompressionCodec_SNAPPY
+
+	for i := len(bars) - 1; i >= 0; i-- {
+		b := bars[i]
+		r := row{b.Time.UnixMilli(), b.Open, b.High, b.Low, b.Close, b.Volume, b.QuoteVolume, b.Trades}
+		if err := pw.Write(r); err != nil {
+			return err
+		}
+	}
+
+	return pw.WriteStop()
+}
+
+// Read loads bars from a parquet file written by Write
+func Read(filename string) (history.Bars, error) {
+	fr, err := local.NewLocalFileReader(filename)
+	if err != nil {
+		return nil, err
+	}
+	defer fr.Close()
+
+	pr, err := reader.NewParquetReader(fr, new(row), 1)
+	if err != nil {
+		return nil, err
+	}
+	defer pr.ReadStop()
+
+	rows := make([]row, pr.GetNumRows())
+	if err := pr.Read(&rows); err != nil {
+		return nil, err
+	}
+
+	bars := make(history.Bars, len(rows))
+	for i, r := range rows {
+		bars[i] = history.Bar{
+			Time:        time.UnixMilli(r.Time).UTC(),
+			Open:        r.Open,
+			High:        r.High,
+			Low:         r.Low,
+			Close:       r.Close,
+			Volume:      r.Volume,
+			QuoteVolume: r.QuoteVolume,
+			Trades:      r.Trades,
+		}
+	}
+
+	return bars.Sort(), nil
+}
@@ -0,0 +1,40 @@
+package parquet
+
+import (
+	"path/filepath"
+	"reflect"
+	"testing"
+	"time"
+
+	"github.com/slicken/history"
+)
+
+func TestRoundTrip(t *testing.T) {
+	start := time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)
+	var bars history.Bars
+	for i := 9; i >= 0; i-- {
+		c := 100 + float64(i)
+		bars = append(bars, history.Bar{
+			Time:        start.Add(time.Duration(i) * time.Hour),
+			Open:        c,
+			High:        c + 1,
+			Low:         c - 1,
+			Close:       c,
+			Volume:      10,
+			QuoteVolume: 10 * c,
+			Trades:      int64(i + 1),
+		})
+	}
+
+	filename := filepath.Join(t.TempDir(), "bars.parquet")
+	if err := Write(filename, bars); err != nil {
+		t.Fatal(err)
+	}
+	got, err := Read(filename)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !reflect.DeepEqual(got, bars) {
+		t.Fatalf("got %v, want %v", got, bars)
+	}
+}
@@ -0,0 +1,119 @@
+package history
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"time"
+)
+
+// Predictor interface plugs models that predict the next price
+type Predictor interface {
+	Predict(symbol string, bars Bars) (float64, error)
+}
+
+// MultiPredictor is a Predictor that can predict several bars ahead
+type MultiPredictor interface {
+	Predictor
+	PredictN(symbol string, bars Bars) ([]float64, error)
+}
+
+// ForecastEvents returns FORECAST events of predicted prices,
+// one bar period apart starting the bar after the last bar
+func ForecastEvents(symbol string, bars Bars, prices []float64) Events {
+	var events Events
+	period := bars.Period()
+
+	for k, price := range prices {
+		event := NewEvent(symbol)
+		event.Type = FORECAST
+		event.Name = "Forecast"
+		event.Time = bars.LastBar().T().Add(time.Duration(k+1) * period)
+		event.Price = price
+		events = append(events, event)
+	}
+	return events
+}
+
+// HTTPPredictor posts the last WindowSize bars to a prediction server
+//
+//	request:  {"symbol": "BTCUSDT1h", "bars": [{"Time": 1600000000, "Open": 1, ...}, ...]}
+//	response: {"prediction": 10123.5} or {"prediction": [10123.5, 10150.2, ...]}
+//
+// bars are sent oldest first
+type HTTPPredictor struct {
+	URL        string
+	WindowSize int
+	Timeout    time.Duration
+}
+
+// NewHTTPPredictor returns a HTTPPredictor with a 10 second timeout
+func NewHTTPPredictor(url string, windowSize int) *HTTPPredictor {
+	return &HTTPPredictor{URL: url, WindowSize: windowSize, Timeout: 10 * time.Second}
+}
+
+// Predict the next price of symbol
+func (p *HTTPPredictor) Predict(symbol string, bars Bars) (float64, error) {
+	prices, err := p.PredictN(symbol, bars)
+	if err != nil {
+		return 0, err
+	}
+	return prices[0], nil
+}
+
+// PredictN the next prices of symbol, a single value response returns one price
+func (p *HTTPPredictor) PredictN(symbol string, bars Bars) ([]float64, error) {
+	if p.WindowSize > len(bars) {
+		return nil, fmt.Errorf("need %d bars, got %d", p.WindowSize, len(bars))
+	}
+	window := bars
+	if p.WindowSize > 0 {
+		window = bars[:p.WindowSize]
+	}
+
+	body, err := json.Marshal(map[string]interface{}{
+		"symbol": symbol,
+		"bars":   window.Reverse(),
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	client := &http.Client{Timeout: p.Timeout}
+	resp, err := client.Post(p.URL, "application/json", bytes.NewReader(body))
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("%s: %s", p.URL, resp.Status)
+	}
+	b, err := io.ReadAll(resp.Body)
+	if err != nil {
+		return nil, err
+	}
+
+	var v struct {
+		Prediction json.RawMessage `json:"prediction"`
+	}
+	if err := json.Unmarshal(b, &v); err != nil {
+		return nil, err
+	}
+
+	// single value or list of values
+	var prices []float64
+	var price float64
+	if err := json.Unmarshal(v.Prediction, &price); err == nil {
+		prices = []float64{price}
+	} else if err := json.Unmarshal(v.Prediction, &prices); err != nil {
+		return nil, fmt.Errorf("invalid prediction: %v", err)
+	}
+	if len(prices) == 0 {
+		return nil, errors.New("no prediction in response")
+	}
+
+	return prices, nil
+}
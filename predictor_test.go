@@ -0,0 +1,48 @@
+package history
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+)
+
+func TestHTTPPredictor(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		var req struct {
+			Symbol string
+			Bars   Bars
+		}
+		if err := json.NewDecoder(r.Body).Decode(&req); err != nil || req.Symbol != "BTCUSDT1h" || len(req.Bars) != 3 {
+			http.Error(w, "bad request", http.StatusBadRequest)
+			return
+		}
+		// bars are sent oldest first, predict the last close plus one
+		last := req.Bars[len(req.Bars)-1].Close
+		if r.URL.Path == "/n" {
+			json.NewEncoder(w).Encode(map[string]interface{}{"prediction": []float64{last + 1, last + 2}})
+			return
+		}
+		json.NewEncoder(w).Encode(map[string]interface{}{"prediction": last + 1})
+	}))
+	defer srv.Close()
+
+	bars := makeBars(10, time.Hour)
+	price, err := NewHTTPPredictor(srv.URL, 3).Predict("BTCUSDT1h", bars)
+	if err != nil || price != bars[0].Close+1 {
+		t.Fatalf("Predict %v (%v), want %v", price, err, bars[0].Close+1)
+	}
+	prices, err := NewHTTPPredictor(srv.URL+"/n", 3).PredictN("BTCUSDT1h", bars)
+	if err != nil || len(prices) != 2 {
+		t.Fatalf("PredictN %v (%v), want 2 prices", prices, err)
+	}
+	if _, err := NewHTTPPredictor(srv.URL, 20).Predict("BTCUSDT1h", bars); err == nil {
+		t.Fatal("want error for too few bars")
+	}
+
+	events := ForecastEvents("BTCUSDT1h", bars, prices)
+	if len(events) != 2 || events[0].Type != FORECAST || !events[1].Time.Equal(bars[0].Time.Add(2*time.Hour)) {
+		t.Fatalf("got forecast events %v", events)
+	}
+}
@@ -0,0 +1,38 @@
+package history
+
+// Renko converts bars into renko bricks of brickSize, based on close to close moves.
+// Each brick is a bar with the brick open and close, timestamped
+// with the time of the bar that completed the brick
+func (bars Bars) Renko(brickSize float64) Bars {
+	if brickSize <= 0 || len(bars) == 0 {
+		return Bars{}
+	}
+
+	var bricks Bars
+	last := bars[len(bars)-1].Close
+
+	for i := len(bars) - 2; i >= 0; i-- {
+		b := bars[i]
+		// up bricks
+		for b.Close >= last+brickSize {
+			bricks = append(bricks, Bar{Time: b.Time, Open: last, High: last + brickSize, Low: last, Close: last + brickSize})
+			last += brickSize
+		}
+		// down bricks
+		for b.Close <= last-brickSize {
+			bricks = append(bricks, Bar{Time: b.Time, Open: last, High: last, Low: last - brickSize, Close: last - brickSize})
+			last -= brickSize
+		}
+	}
+
+	return bricks.Reverse()
+}
+
+// RenkoATR converts bars into renko bricks sized by the ATR of the last period bars
+func (bars Bars) RenkoATR(period int) Bars {
+	if period <= 0 || period > len(bars) {
+		return Bars{}
+	}
+
+	return bars.Renko(bars[:period].ATR())
+}
@@ -0,0 +1,158 @@
+package history
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// RESTDownloader is a Downloader for json rest apis configured without code
+//
+//	hist.Downloader = &history.RESTDownloader{
+//		URL:        "https://api.binance.com/api/v3/klines?symbol={symbol}&interval={timeframe}&limit={limit}",
+//		Fields:     history.RESTFields{Time: "0", Open: "1", High: "2", Low: "3", Close: "4", Volume: "5"},
+//		TimeMillis: true,
+//	}
+type RESTDownloader struct {
+	// URL template with {symbol}, {timeframe} and {limit} placeholders
+	URL string
+	// Root is a dot separated path to the bars array in the response, empty if response is the array
+	Root string
+	// Fields maps bar fields to their array index or object key
+	Fields RESTFields
+	// TimeMillis if time field is unix milliseconds instead of seconds
+	TimeMillis bool
+	// Timeframes translates our timeframes to the api ones ("1h" => "60"), optional
+	Timeframes map[string]string
+	// Client to use, defaults to http.DefaultClient
+	Client *http.Client
+}
+
+// RESTFields holds array index ("0") or object key ("open") for each bar field
+type RESTFields struct {
+	Time, Open, High, Low, Close, Volume string
+}
+
+// GetKlines downloads bars from the rest api
+func (d *RESTDownloader) GetKlines(pair, timeframe string, limit int) (Bars, error) {
+	if tf, ok := d.Timeframes[timeframe]; ok {
+		timeframe = tf
+	}
+	url := strings.NewReplacer(
+		"{symbol}", pair,
+		"{timeframe}", timeframe,
+		"{limit}", strconv.Itoa(limit),
+	).Replace(d.URL)
+
+	req, err := http.NewRequest("GET", url, nil)
+	if err != nil {
+		return nil, err
+	}
+	req.Header.Add("Accept", "application/json")
+
+	client := d.Client
+	if client == nil {
+		client = http.DefaultClient
+	}
+	resp, err := client.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("%s: %s", url, resp.Status)
+	}
+	b, err := io.ReadAll(resp.Body)
+	if err != nil {
+		return nil, err
+	}
+
+	var v interface{}
+	if err := json.Unmarshal(b, &v); err != nil {
+		return nil, err
+	}
+
+	// walk to bars array
+	if d.Root != "" {
+		for _, key := range strings.Split(d.Root, ".") {
+			m, ok := v.(map[string]interface{})
+			if !ok {
+				return nil, fmt.Errorf("root %q not found", d.Root)
+			}
+			v = m[key]
+		}
+	}
+	rows, ok := v.([]interface{})
+	if !ok {
+		return nil, fmt.Errorf("root %q is not an array", d.Root)
+	}
+
+	var bars = make(Bars, 0, len(rows))
+	for i, row := range rows {
+		bar, err := d.parseBar(row)
+		if err != nil {
+			return nil, fmt.Errorf("bar %d: %v", i, err)
+		}
+		bars = append(bars, bar)
+	}
+
+	return bars.Sort(), nil
+}
+
+// parseBar maps one row of the response to a bar
+func (d *RESTDownloader) parseBar(row interface{}) (Bar, error) {
+	var bar Bar
+
+	ts, err := restFloat(row, d.Fields.Time)
+	if err != nil {
+		return bar, err
+	}
+	if d.TimeMillis {
+		bar.Time = time.Unix(int64(ts)/1000, 0).UTC()
+	} else {
+		bar.Time = time.Unix(int64(ts), 0).UTC()
+	}
+
+	keys := []string{d.Fields.Open, d.Fields.High, d.Fields.Low, d.Fields.Close}
+	fields := []*float64{&bar.Open, &bar.High, &bar.Low, &bar.Close}
+	for n, field := range fields {
+		if *field, err = restFloat(row, keys[n]); err != nil {
+			return bar, err
+		}
+	}
+	// volume is optional
+	if d.Fields.Volume != "" {
+		if bar.Volume, err = restFloat(row, d.Fields.Volume); err != nil {
+			return bar, err
+		}
+	}
+
+	return bar, nil
+}
+
+// restFloat returns the value of key in row as float, row is an array or object
+func restFloat(row interface{}, key string) (float64, error) {
+	var v interface{}
+
+	switch r := row.(type) {
+	case []interface{}:
+		n, err := strconv.Atoi(key)
+		if err != nil || n < 0 || n >= len(r) {
+			return 0, fmt.Errorf("index %q out of range", key)
+		}
+		v = r[n]
+	case map[string]interface{}:
+		var ok bool
+		if v, ok = r[key]; !ok {
+			return 0, fmt.Errorf("key %q not found", key)
+		}
+	default:
+		return 0, fmt.Errorf("unknown row type %T", row)
+	}
+
+	return strconv.ParseFloat(fmt.Sprintf("%v", v), 64)
+}
@@ -0,0 +1,59 @@
+package history
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+)
+
+func TestRESTDownloader(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch r.URL.Path {
+		case "/array":
+			if r.URL.Query().Get("interval") != "60" || r.URL.Query().Get("limit") != "2" {
+				http.Error(w, "bad query", http.StatusBadRequest)
+				return
+			}
+			w.Write([]byte(`[[1704067200000,"1","2","0.5","1.5","10"],[1704070800000,"2","3","1","2.5","20"]]`))
+		case "/object":
+			w.Write([]byte(`{"data":{"candles":[{"t":1704067200,"o":1,"h":2,"l":0.5,"c":1.5}]}}`))
+		default:
+			http.NotFound(w, r)
+		}
+	}))
+	defer srv.Close()
+
+	d := &RESTDownloader{
+		URL:        srv.URL + "/array?symbol={symbol}&interval={timeframe}&limit={limit}",
+		Fields:     RESTFields{Time: "0", Open: "1", High: "2", Low: "3", Close: "4", Volume: "5"},
+		TimeMillis: true,
+		Timeframes: map[string]string{"1h": "60"},
+	}
+	bars, err := d.GetKlines("BTCUSDT", "1h", 2)
+	if err != nil {
+		t.Fatal(err)
+	}
+	// newest first
+	if len(bars) != 2 || !bars[0].Time.Equal(start.Add(time.Hour)) || bars[0].Close != 2.5 || bars[1].Volume != 10 {
+		t.Fatalf("got %v", bars)
+	}
+
+	d = &RESTDownloader{
+		URL:    srv.URL + "/object",
+		Root:   "data.candles",
+		Fields: RESTFields{Time: "t", Open: "o", High: "h", Low: "l", Close: "c"},
+	}
+	if bars, err = d.GetKlines("BTCUSDT", "1h", 1); err != nil || len(bars) != 1 || !bars[0].Time.Equal(start) {
+		t.Fatalf("got %v (%v)", bars, err)
+	}
+
+	d.Root = "data.missing"
+	if _, err := d.GetKlines("BTCUSDT", "1h", 1); err == nil {
+		t.Fatal("want error for missing root")
+	}
+	d.URL = srv.URL + "/nothing"
+	if _, err := d.GetKlines("BTCUSDT", "1h", 1); err == nil {
+		t.Fatal("want error for not found")
+	}
+}
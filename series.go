@@ -0,0 +1,229 @@
+package history
+
+import (
+	"math"
+)
+
+/*
+	Series returns one indicator value for every bar, aligned with bars
+	so series[i] is the value at bars[i] (newest first).
+	Warm-up bars without enough history are math.NaN()
+*/
+
+// nanSeries returns a series of n NaN values
+func nanSeries(n int) []float64 {
+	series := make([]float64, n)
+	for i := range series {
+		series[i] = math.NaN()
+	}
+	return series
+}
+
+// SMASeries returns SMA of period for every bar
+func (bars Bars) SMASeries(period int, mode Price) []float64 {
+	series := nanSeries(len(bars))
+	if period <= 0 || period > len(bars) {
+		return series
+	}
+
+	var sum float64
+	for i := len(bars) - 1; i >= 0; i-- {
+		sum += bars[i].Mode(mode)
+		if i+period < len(bars) {
+			sum -= bars[i+period].Mode(mode)
+		}
+		if i+period <= len(bars) {
+			series[i] = sum / float64(period)
+		}
+	}
+
+	return series
+}
+
+// EMASeries returns EMA of period for every bar, seeded with SMA of the oldest period bars
+func (bars Bars) EMASeries(period int, mode Price) []float64 {
+	series := nanSeries(len(bars))
+	if period <= 0 || period > len(bars) {
+		return series
+	}
+
+	seed := len(bars) - period
+	ema, _ := bars[seed:].SMAn(period, mode)
+	series[seed] = ema
+
+	k := 2 / (float64(period) + 1)
+	for i := seed - 1; i >= 0; i-- {
+		ema = bars[i].Mode(mode)*k + ema*(1-k)
+		series[i] = ema
+	}
+
+	return series
+}
+
+// RSISeries returns RSI of period closes for every bar, using Wilder's smoothing
+func (bars Bars) RSISeries(period int) []float64 {
+	series := nanSeries(len(bars))
+	if period <= 0 || period+1 > len(bars) {
+		return series
+	}
+
+	// seed with average gain and loss of the oldest period changes
+	var gain, loss float64
+	last := len(bars) - 1
+	for i := last - 1; i >= last-period; i-- {
+		change := bars[i].Close - bars[i+1].Close
+		if change > 0 {
+			gain += change
+		} else {
+			loss -= change
+		}
+	}
+	gain /= float64(period)
+	loss /= float64(period)
+	series[last-period] = rsi(gain, loss)
+
+	for i := last - period - 1; i >= 0; i-- {
+		change := bars[i].Close - bars[i+1].Close
+		g, l := math.Max(change, 0), math.Max(-change, 0)
+		gain = (gain*float64(period-1) + g) / float64(period)
+		loss = (loss*float64(period-1) + l) / float64(period)
+		series[i] = rsi(gain, loss)
+	}
+
+	return series
+}
+
+// RSI of period closes at the last bar, NaN if not enough bars
+func (bars Bars) RSI(period int) float64 {
+	if len(bars) == 0 {
+		return math.NaN()
+	}
+	return bars.RSISeries(period)[0]
+}
+
+// rsi from average gain and loss
+func rsi(gain, loss float64) float64 {
+	if loss == 0 {
+		if gain == 0 {
+			return 50
+		}
+		return 100
+	}
+	return 100 - 100/(1+gain/loss)
+}
+
+// ROCSeries returns ROC of period for every bar
+func (bars Bars) ROCSeries(period int) []float64 {
+	series := nanSeries(len(bars))
+	for i := range series {
+		series[i] = bars[i:].ROC(period)
+	}
+	return series
+}
+
+// MACDSeries returns macd line (fast ema - slow ema of closes), its signal ema
+// and histogram (macd - signal) for every bar
+func (bars Bars) MACDSeries(fast, slow, signal int) (macd, sig, hist []float64) {
+	macd = nanSeries(len(bars))
+	fastEMA := bars.EMASeries(fast, C)
+	slowEMA := bars.EMASeries(slow, C)
+	for i := range macd {
+		macd[i] = fastEMA[i] - slowEMA[i]
+	}
+
+	sig = emaOf(macd, signal)
+	hist = nanSeries(len(bars))
+	for i := range hist {
+		hist[i] = macd[i] - sig[i]
+	}
+
+	return macd, sig, hist
+}
+
+// StochSeries returns stochastic %K of k bars smoothed by smooth, and %D as sma of d %K values
+func (bars Bars) StochSeries(k, smooth, d int) (K, D []float64) {
+	raw := nanSeries(len(bars))
+	if k > 0 {
+		for i := 0; i+k <= len(bars); i++ {
+			window := bars[i : i+k]
+			high, low := window.Highest(H), window.Lowest(L)
+			raw[i] = 50
+			if high > low {
+				raw[i] = 100 * (bars[i].Close - low) / (high - low)
+			}
+		}
+	}
+
+	K = smaOf(raw, smooth)
+	D = smaOf(K, d)
+	return K, D
+}
+
+// smaOf returns sma of period for every value (newest first), NaN when window has NaN
+func smaOf(values []float64, period int) []float64 {
+	series := nanSeries(len(values))
+	if period <= 0 {
+		return series
+	}
+
+	for i := 0; i+period <= len(values); i++ {
+		var sum float64
+		for _, v := range values[i : i+period] {
+			sum += v
+		}
+		// NaN spreads through sum
+		series[i] = sum / float64(period)
+	}
+
+	return series
+}
+
+// emaOf returns ema of period for every value (newest first), seeded with sma
+// of the oldest period values that are not NaN
+func emaOf(values []float64, period int) []float64 {
+	series := nanSeries(len(values))
+	if period <= 0 {
+		return series
+	}
+
+	// skip NaN warm-up of values
+	oldest := len(values) - 1
+	for oldest >= 0 && math.IsNaN(values[oldest]) {
+		oldest--
+	}
+	seed := oldest - period + 1
+	if seed < 0 {
+		return series
+	}
+
+	var ema float64
+	for _, v := range values[seed : oldest+1] {
+		ema += v
+	}
+	ema /= float64(period)
+	series[seed] = ema
+
+	k := 2 / (float64(period) + 1)
+	for i := seed - 1; i >= 0; i-- {
+		ema = values[i]*k + ema*(1-k)
+		series[i] = ema
+	}
+
+	return series
+}
+
+// CrossOver returns true if series a crossed above b on the latest value (newest first)
+func CrossOver(a, b []float64) bool {
+	if len(a) < 2 || len(b) < 2 {
+		return false
+	}
+	return a[0] > b[0] && a[1] <= b[1]
+}
+
+// CrossUnder returns true if series a crossed below b on the latest value (newest first)
+func CrossUnder(a, b []float64) bool {
+	if len(a) < 2 || len(b) < 2 {
+		return false
+	}
+	return a[0] < b[0] && a[1] >= b[1]
+}
@@ -0,0 +1,23 @@
+package history
+
+import "testing"
+
+func TestCrossOverAndUnder(t *testing.T) {
+	for _, tc := range []struct {
+		a, b        []float64
+		over, under bool
+	}{
+		{[]float64{2, 1}, []float64{1.5, 1.5}, true, false},
+		{[]float64{2, 1}, []float64{1.5, 1}, true, false},
+		{[]float64{1, 2}, []float64{1.5, 1.5}, false, true},
+		{[]float64{2, 2}, []float64{1, 1}, false, false},
+		{[]float64{2}, []float64{1}, false, false},
+	} {
+		if got := CrossOver(tc.a, tc.b); got != tc.over {
+			t.Errorf("CrossOver(%v, %v) = %v, want %v", tc.a, tc.b, got, tc.over)
+		}
+		if got := CrossUnder(tc.a, tc.b); got != tc.under {
+			t.Errorf("CrossUnder(%v, %v) = %v, want %v", tc.a, tc.b, got, tc.under)
+		}
+	}
+}
@@ -1,6 +1,7 @@
 package history
 
 import (
+	"sort"
 	"time"
 )
 
@@ -28,7 +29,9 @@ func (bars Bars) Stream() <-chan Bars {
 	return c
 }
 
-// Stream bars
+// StreamInterval streams every real bar from start to end (inclusive) in time order,
+// as a copy of bars from start up to that bar (newest first).
+// Bars closer then interval to the previous streamed bar are skipped
 func (bars Bars) StreamInterval(start, end time.Time, interval time.Duration) <-chan Bars {
 	c := make(chan Bars, 1)
 
@@ -38,18 +41,6 @@ func (bars Bars) StreamInterval(start, end time.Time, interval time.Duration) <-
 		return c
 	}
 
-	// check if our time is within bars first last times, adjust if needed
-	if ft := bars.FirstBar().T(); !ft.IsZero() {
-		if start.IsZero() || start.Before(ft) {
-			start = ft
-		}
-	}
-	if lt := bars.LastBar().T(); !lt.IsZero() {
-		if end.IsZero() || end.After(lt) {
-			end = lt
-		}
-	}
-
 	// adjust interval if needed
 	if interval < mindur {
 		interval = mindur
@@ -58,19 +49,77 @@ func (bars Bars) StreamInterval(start, end time.Time, interval time.Duration) <-
 		interval = maxdur
 	}
 
+	// oldest bar at or after start and newest bar at or before end
+	first := sort.Search(len(bars), func(i int) bool {
+		return bars[i].Time.Before(start)
+	}) - 1
+	last := 0
+	if !end.IsZero() {
+		last = sort.Search(len(bars), func(i int) bool {
+			return !bars[i].Time.After(end)
+		})
+	}
+
 	go func() {
-		// time value witch we will increase on loop
-		dt := start
-		for dt.Before(end) {
-			// add looping interval to time
-			dt = dt.Add(interval)
-			// get bars from timespan
-			stream := bars.TimeSpan(start, dt)
+		var prev time.Time
+		for i := first; i >= last; i-- {
+			if !prev.IsZero() && bars[i].Time.Sub(prev) < interval {
+				continue
+			}
+			prev = bars[i].Time
 
+			stream := make(Bars, first-i+1)
+			copy(stream, bars[i:first+1])
 			c <- stream
 		}
 		close(c)
+	}()
+
+	return c
+}
+
+// StreamWindows streams every bar from start to end (inclusive) in time order,
+// as all bars up to that bar (newest first). Windows are re-slices of bars
+// and share their storage, so they must not be modified
+func (bars Bars) StreamWindows(start, end time.Time) <-chan Bars {
+	c := make(chan Bars, 1)
+
+	// oldest bar at or after start and newest bar at or before end
+	first := sort.Search(len(bars), func(i int) bool {
+		return bars[i].Time.Before(start)
+	}) - 1
+	last := 0
+	if !end.IsZero() {
+		last = sort.Search(len(bars), func(i int) bool {
+			return !bars[i].Time.After(end)
+		})
+	}
+
+	go func() {
+		for i := first; i >= last; i-- {
+			c <- bars[i:]
+		}
+		close(c)
+	}()
+
+	return c
+}
+
+// StreamLookback streams a window of the n most recent bars (newest first)
+// advancing one bar at a time in time order. Windows share storage with bars
+func (bars Bars) StreamLookback(n int) <-chan Bars {
+	c := make(chan Bars, 1)
+
+	if n <= 0 || n > len(bars) {
+		defer close(c)
+		return c
+	}
 
+	go func() {
+		for i := len(bars) - n; i >= 0; i-- {
+			c <- bars[i : i+n]
+		}
+		close(c)
 	}()
 
 	return c
@@ -0,0 +1,128 @@
+package history
+
+import (
+	"sync"
+)
+
+// BarUpdate is sent to subscribers when bars of symbol are added or corrected
+type BarUpdate struct {
+	Symbol string
+	Bars   Bars
+}
+
+// subscriber queues updates so a slow reader never blocks history or misses one
+type subscriber struct {
+	c      chan BarUpdate
+	queue  []BarUpdate
+	signal chan struct{}
+	done   chan struct{}
+
+	sync.Mutex
+}
+
+// push update to queue and wake pump
+func (s *subscriber) push(u BarUpdate) {
+	s.Lock()
+	s.queue = append(s.queue, u)
+	s.Unlock()
+
+	select {
+	case s.signal <- struct{}{}:
+	default:
+	}
+}
+
+// pump sends queued updates in order until done
+func (s *subscriber) pump() {
+	defer close(s.c)
+	for {
+		s.Lock()
+		if len(s.queue) == 0 {
+			s.Unlock()
+			select {
+			case <-s.signal:
+				continue
+			case <-s.done:
+				return
+			}
+		}
+		u := s.queue[0]
+		s.queue[0] = BarUpdate{}
+		s.queue = s.queue[1:]
+		s.Unlock()
+
+		select {
+		case s.c <- u:
+		case <-s.done:
+			// keep u for drain
+			s.Lock()
+			s.queue = append([]BarUpdate{u}, s.queue...)
+			s.Unlock()
+			return
+		}
+	}
+}
+
+// Subscribe returns a channel receiving every BarUpdate from now on, in order.
+// updates queue until read, call Unsubscribe when done to release them
+func (h *History) Subscribe() <-chan BarUpdate {
+	s := &subscriber{
+		c:      make(chan BarUpdate),
+		signal: make(chan struct{}, 1),
+		done:   make(chan struct{}),
+	}
+	go s.pump()
+
+	h.Lock()
+	h.subscribers = append(h.subscribers, s)
+	h.Unlock()
+
+	return s.c
+}
+
+// Unsubscribe stops updates to c and closes it
+func (h *History) Unsubscribe(c <-chan BarUpdate) {
+	h.unsubscribe(c)
+}
+
+// unsubscribe removes subscriber of c and stops its pump, nil if not subscribed
+func (h *History) unsubscribe(c <-chan BarUpdate) *subscriber {
+	h.Lock()
+	defer h.Unlock()
+
+	for i, s := range h.subscribers {
+		if s.c == c {
+			close(s.done)
+			h.subscribers = remove(h.subscribers, i)
+			return s
+		}
+	}
+	return nil
+}
+
+// drain unsubscribes c and calls fn with every update still pending, in order
+func (h *History) drain(c <-chan BarUpdate, fn func(BarUpdate)) {
+	s := h.unsubscribe(c)
+	if s == nil {
+		return
+	}
+	// pump may still send some before it closes c
+	for u := range c {
+		fn(u)
+	}
+
+	s.Lock()
+	queue := s.queue
+	s.queue = nil
+	s.Unlock()
+	for _, u := range queue {
+		fn(u)
+	}
+}
+
+// publish update to all subscribers, call with lock held
+func (h *History) publish(symbol string, bars Bars) {
+	for _, s := range h.subscribers {
+		s.push(BarUpdate{symbol, bars})
+	}
+}
@@ -0,0 +1,91 @@
+package history
+
+import (
+	"fmt"
+	"sync"
+	"testing"
+	"time"
+)
+
+func TestSubscribeStress(t *testing.T) {
+	h := newTestHistory(t)
+	h.SetPersist(false)
+
+	const symbols, adds = 20, 25
+	subs := make([]<-chan BarUpdate, 4)
+	for i := range subs {
+		subs[i] = h.Subscribe()
+	}
+
+	var wg sync.WaitGroup
+	for n := 0; n < symbols; n++ {
+		wg.Add(1)
+		go func(n int) {
+			defer wg.Done()
+			symbol := fmt.Sprintf("S%dUSDT1h", n)
+			bars := makeBars(adds+1, time.Hour)
+			h.Add(symbol, bars[adds-1:])
+			for i := adds - 2; i >= 0; i-- {
+				h.Add(symbol, bars[i:i+1])
+			}
+		}(n)
+	}
+	wg.Wait()
+
+	// every add is one update for every subscriber
+	for i, c := range subs {
+		got := 0
+		timeout := time.After(5 * time.Second)
+	read:
+		for got < symbols*adds {
+			select {
+			case <-c:
+				got++
+			case <-timeout:
+				break read
+			}
+		}
+		h.Unsubscribe(c)
+		for range c {
+			got++
+		}
+		if got != symbols*adds {
+			t.Errorf("subscriber %d got %d updates, want %d", i, got, symbols*adds)
+		}
+	}
+}
+
+func TestSubscribeSendsAddedBars(t *testing.T) {
+	h := newTestHistory(t)
+	h.SetPersist(false)
+	c := h.Subscribe()
+	next := func() BarUpdate {
+		select {
+		case u := <-c:
+			return u
+		case <-time.After(5 * time.Second):
+			t.Fatal("no update")
+		}
+		return BarUpdate{}
+	}
+
+	bars := makeBars(10, time.Hour)
+	h.Add("BTCUSDT1h", bars[1:])
+	if u := next(); u.Symbol != "BTCUSDT1h" || len(u.Bars) != 9 {
+		t.Fatalf("got %s with %d bars, want BTCUSDT1h with 9", u.Symbol, len(u.Bars))
+	}
+	h.Add("BTCUSDT1h", bars[:1])
+	if u := next(); u.Symbol != "BTCUSDT1h" || len(u.Bars) != 1 || u.Bars[0] != bars[0] {
+		t.Fatalf("got %s with %v, want the new bar", u.Symbol, u.Bars)
+	}
+	corrected := makeBars(10, time.Hour, 50)[3:4]
+	h.Correct("BTCUSDT1h", corrected)
+	if u := next(); u.Bars[0] != corrected[0] {
+		t.Fatalf("got %v, want corrected bar", u.Bars)
+	}
+
+	h.Unsubscribe(c)
+	if _, ok := <-c; ok {
+		t.Fatal("channel not closed after Unsubscribe")
+	}
+}
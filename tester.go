@@ -4,6 +4,9 @@ import (
 	"errors"
 	"fmt"
 	"log"
+	"runtime"
+	"sort"
+	"sync"
 	"time"
 )
 
@@ -14,22 +17,214 @@ type Tester interface {
 	Test() (Events, error)
 }
 
+// MultiStrategy interface using bars of all symbols to output Events,
+// for pairs and basket strategies
+type MultiStrategy interface {
+	OnBars(map[string]Bars) (Events, bool)
+}
+
 // Test strategys compatible with both Strategy (bars) and MultiStrategy (whole history struct)
+// strategy runs on every bar from start to end, with all bars before as history.
+// strategies that also implement MultiStrategy are run by TestMulti
 func (hist *History) Test(strategy Strategy, start, end time.Time) (Events, error) {
-	if len(hist.bars) == 0 {
+	if ms, ok := strategy.(MultiStrategy); ok {
+		return hist.TestMulti(ms, start, end)
+	}
+
+	m := hist.Map()
+	if len(m) == 0 {
 		return nil, errors.New("no history")
 	}
 
+	if ps, ok := strategy.(PortfolioStrategy); ok {
+		ps.Reset()
+	}
+
 	var events Events
-	log.Printf("[TEST] %s (start: %v ==> end: %v)\n", fmt.Sprintf("%T", strategy)[6:], start.Format(dt_stamp), end.Format(dt_stamp))
+	log.Printf("[TEST] %s (start: %v ==> end: %v)\n", strategyName(strategy), start.Format(dt_stamp), end.Format(dt_stamp))
+
+	hist.RLock()
+	progress, parallel, clock := hist.progress, hist.parallel, hist.clock
+	hist.RUnlock()
+
+	workers := 1
+	if parallel {
+		workers = runtime.GOMAXPROCS(0)
+		// one clock can not be at the bars of every worker
+		clock = nil
+	}
+
+	jobs := make(chan string)
+	var mu sync.Mutex
+	var done int
+	// events by symbol, merged in symbol order so results do not depend on scheduling
+	results := make(map[string]Events, len(m))
+
+	var wg sync.WaitGroup
+	for w := 0; w < workers; w++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+
+			for symbol := range jobs {
+				var evs Events
+				runBars(strategy, symbol, m[symbol], start, end, &evs, nil, clock)
+
+				mu.Lock()
+				results[symbol] = evs
+				done++
+				if progress != nil {
+					progress(done, len(m))
+				}
+				mu.Unlock()
+			}
+		}()
+	}
+	for _, symbol := range symbols(m) {
+		jobs <- symbol
+	}
+	close(jobs)
+	wg.Wait()
+	for _, symbol := range symbols(m) {
+		events = append(events, results[symbol]...)
+	}
+	events.SortByTime()
+
+	log.Printf("[TEST] completed with %d Events\n", len(events))
+	return events, nil
+}
+
+// SetProgress sets a callback called by Test each time a symbol is done, nil disables it
+func (hist *History) SetProgress(f func(done, total int)) {
+	hist.Lock()
+	hist.progress = f
+	hist.Unlock()
+}
 
-	for symbol, bars := range hist.bars {
-		for streamedBars := range bars.StreamInterval(start, end, bars.Period()) {
-			if event, ok := strategy.Run(symbol, streamedBars); ok {
+// SetParallel runs Test on symbols in parallel, only for strategies that
+// do not share state between symbols (like one portfolio for all symbols).
+// a FakeClock set with SetClock is not moved by parallel tests
+func (hist *History) SetParallel(v bool) {
+	hist.Lock()
+	hist.parallel = v
+	hist.Unlock()
+}
+
+// TestResult of a test, Symbol is empty for tests of all symbols
+type TestResult struct {
+	Symbol string
+	Events Events
+	// Bars is the number of bars the strategy ran on
+	Bars int
+	// Portfolio of events opened and closed in order, with bracket exits like PortfolioTest
+	Portfolio *Portfolio
+}
+
+// TestSymbol runs strategy only on bars of symbol
+func (hist *History) TestSymbol(strategy Strategy, symbol string, start, end time.Time) (*TestResult, error) {
+	bars := hist.Bars(symbol)
+	if len(bars) == 0 {
+		return nil, fmt.Errorf("no history for %s", symbol)
+	}
+
+	if ps, ok := strategy.(PortfolioStrategy); ok {
+		ps.Reset()
+	}
+
+	result := &TestResult{Symbol: symbol}
+	hist.RLock()
+	result.Portfolio = NewPortfolio(hist.balance)
+	clock := hist.clock
+	hist.RUnlock()
+	result.Bars = runBars(strategy, symbol, bars, start, end, &result.Events, result.Portfolio, clock)
+	result.Events.SortByTime()
+
+	log.Printf("[TEST] %s completed %d bars with %d Events\n", symbol, result.Bars, len(result.Events))
+	return result, nil
+}
+
+// runBars runs strategy on every bar from start to end and adds events,
+// returns number of bars. with a wallet, bracket exits are checked before
+// every run and new events open and close positions. a FakeClock is moved to
+// the time of every bar, and events without time get the time of their bar
+func runBars(strategy Strategy, symbol string, bars Bars, start, end time.Time, events *Events, wallet *Portfolio, clock Clock) (n int) {
+	min := minBars(strategy)
+	fake, _ := clock.(*FakeClock)
+	for streamedBars := range bars.StreamWindows(start, end) {
+		bar := streamedBars.LastBar()
+		if fake != nil {
+			fake.Set(bar.T())
+		}
+		if wallet != nil {
+			for _, event := range wallet.exits(symbol, bar) {
+				events.Add(event)
+			}
+		}
+		if len(streamedBars) < min {
+			continue
+		}
+		n++
+		if event, ok := strategy.Run(symbol, streamedBars); ok {
+			if event.Time.IsZero() {
+				event.Time = bar.T()
+			}
+			if events.Add(event) && wallet != nil {
+				wallet.apply(event)
+			}
+		}
+	}
+	return n
+}
+
+// TestMulti runs a MultiStrategy on every timestamp from start to end where any symbol has a bar,
+// in time order. the map holds only symbols with a bar at that timestamp, each with all bars up
+// to and including it (newest first). symbols without a bar at that time are left out, so
+// strategies that need every symbol should check the map. windows share storage with history
+func (hist *History) TestMulti(strategy MultiStrategy, start, end time.Time) (Events, error) {
+	m := hist.Map()
+	if len(m) == 0 {
+		return nil, errors.New("no history")
+	}
+
+	if ps, ok := strategy.(PortfolioStrategy); ok {
+		ps.Reset()
+	}
+
+	var events Events
+	log.Printf("[TEST] %s (start: %v ==> end: %v)\n", strategyName(strategy), start.Format(dt_stamp), end.Format(dt_stamp))
+
+	// all timestamps in range, oldest first
+	seen := make(map[int64]bool)
+	var times []time.Time
+	for _, bars := range m {
+		for _, bar := range bars {
+			if bar.Time.Before(start) || (!end.IsZero() && bar.Time.After(end)) {
+				continue
+			}
+			if !seen[bar.Time.Unix()] {
+				seen[bar.Time.Unix()] = true
+				times = append(times, bar.Time)
+			}
+		}
+	}
+	sort.Slice(times, func(i, j int) bool {
+		return times[i].Before(times[j])
+	})
+
+	for _, t := range times {
+		windows := make(map[string]Bars)
+		for symbol, bars := range m {
+			if n, _ := bars.Find(t); n >= 0 {
+				windows[symbol] = bars[n:]
+			}
+		}
+		if evs, ok := strategy.OnBars(windows); ok {
+			for _, event := range evs {
 				events.Add(event)
 			}
 		}
 	}
+	events.SortByTime()
 
 	log.Printf("[TEST] completed with %d Events\n", len(events))
 	return events, nil
@@ -0,0 +1,112 @@
+package history
+
+import (
+	"fmt"
+	"reflect"
+	"testing"
+	"time"
+)
+
+// everyBar buys on every bar at close
+type everyBar struct{}
+
+func (everyBar) Run(symbol string, bars Bars) (Event, bool) {
+	event := NewEvent(symbol)
+	event.Type = MARKET_BUY
+	event.Time = bars[0].Time
+	event.Price = bars[0].Close
+	return event, true
+}
+
+func TestParallelTestIsDeterministic(t *testing.T) {
+	h := newTestHistory(t)
+	h.SetPersist(false)
+	// same bars for all symbols, so events collide on time and price
+	for n := 0; n < 6; n++ {
+		h.Add(fmt.Sprintf("S%dUSDT1h", n), makeBars(40, time.Hour))
+	}
+
+	serial, err := h.Test(everyBar{}, h.FirstTime(), h.LastTime())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(serial) != 6*40 {
+		t.Fatalf("got %d events, want %d", len(serial), 6*40)
+	}
+
+	h.SetParallel(true)
+	for i := 0; i < 10; i++ {
+		events, err := h.Test(everyBar{}, h.FirstTime(), h.LastTime())
+		if err != nil {
+			t.Fatal(err)
+		}
+		if !reflect.DeepEqual(events, serial) {
+			t.Fatalf("run %d: parallel events differ from serial", i)
+		}
+	}
+}
+
+func TestStrategyName(t *testing.T) {
+	for _, tc := range []struct {
+		strategy interface{}
+		want     string
+	}{
+		{everyBar{}, "everyBar"},
+		{&everyBar{}, "everyBar"},
+		{&brokerStrategy{}, "brokerStrategy"},
+		{nil, ""},
+	} {
+		if got := strategyName(tc.strategy); got != tc.want {
+			t.Errorf("strategyName(%T) = %q, want %q", tc.strategy, got, tc.want)
+		}
+	}
+}
+
+// pairStrategy buys the cheaper of two symbols at every timestamp both have a bar
+type pairStrategy struct {
+	calls, both int
+}
+
+func (s *pairStrategy) OnBars(m map[string]Bars) (Events, bool) {
+	s.calls++
+	a, b := m["AUSDT1h"], m["BUSDT1h"]
+	if a == nil || b == nil {
+		return nil, false
+	}
+	s.both++
+	if !a[0].Time.Equal(b[0].Time) {
+		return nil, false
+	}
+	cheap := a
+	symbol := "AUSDT1h"
+	if b[0].Close < a[0].Close {
+		cheap, symbol = b, "BUSDT1h"
+	}
+	return Events{BuyBracket(symbol, cheap[0].Time, 1, cheap[0].Close, 0, 0)}, true
+}
+
+func (s *pairStrategy) Run(symbol string, bars Bars) (Event, bool) {
+	return Event{}, false
+}
+
+func TestMultiAlignsTimestamps(t *testing.T) {
+	h := newTestHistory(t)
+	h.SetPersist(false)
+	h.Add("AUSDT1h", makeBars(10, time.Hour))
+	// every second hour only, and cheaper
+	b := makeBars(5, 2*time.Hour, 50)
+	h.Add("BUSDT1h", b)
+
+	s := &pairStrategy{}
+	// Test runs strategies that are also a MultiStrategy with TestMulti
+	events, err := h.Test(s, h.FirstTime(), h.LastTime())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if s.calls != 10 || s.both != 5 {
+		t.Fatalf("%d calls with %d for both symbols, want 10 and 5", s.calls, s.both)
+	}
+	if len(events) != 5 || events[0].Symbol != "BUSDT1h" || !events[4].Time.Equal(b[0].Time) {
+		t.Fatalf("got events %v", events)
+	}
+}
@@ -0,0 +1,221 @@
+package tradingview
+
+import (
+	"encoding/json"
+	"errors"
+	"html"
+	"log"
+	"sort"
+	"strings"
+
+	"github.com/slicken/history"
+)
+
+// Chart holds TradingView Lightweight-Charts settings
+type Chart struct {
+	// Volume histogram below price
+	Volume bool
+	// Chart HTTP settings
+	SetWidth, SetHeight string
+}
+
+// DefaultChart returns default chart settings
+func DefaultChart() *Chart {
+	return &Chart{
+		Volume:    true,
+		SetWidth:  "56%",
+		SetHeight: "480px",
+	}
+}
+
+// candle is a lightweight-charts candlestick point
+type candle struct {
+	Time  int64   `json:"time"`
+	Open  float64 `json:"open"`
+	High  float64 `json:"high"`
+	Low   float64 `json:"low"`
+	Close float64 `json:"close"`
+}
+
+// volume is a lightweight-charts histogram point
+type volume struct {
+	Time  int64   `json:"time"`
+	Value float64 `json:"value"`
+	Color string  `json:"color"`
+}
+
+// marker is a lightweight-charts series marker
+type marker struct {
+	Time     int64  `json:"time"`
+	Position string `json:"position"`
+	Color    string `json:"color"`
+	Shape    string `json:"shape"`
+	Text     string `json:"text"`
+}
+
+// MakeCandles = price, oldest bar first
+func MakeCandles(bars history.Bars) ([]byte, error) {
+	var data = make([]candle, 0, len(bars))
+
+	for i := len(bars) - 1; i >= 0; i-- {
+		data = append(data, candle{bars[i].Time.Unix(), bars[i].Open, bars[i].High, bars[i].Low, bars[i].Close})
+	}
+	return json.Marshal(&data)
+}
+
+// MakeVolume = volume colored by bar direction, oldest bar first
+func MakeVolume(bars history.Bars) ([]byte, error) {
+	var data = make([]volume, 0, len(bars))
+
+	for i := len(bars) - 1; i >= 0; i-- {
+		color := "rgba(239,83,80,0.5)"
+		if bars[i].Close >= bars[i].Open {
+			color = "rgba(38,166,154,0.5)"
+		}
+		data = append(data, volume{bars[i].Time.Unix(), bars[i].Volume, color})
+	}
+	return json.Marshal(&data)
+}
+
+// MakeEventMarkers buy events below bar and sell/close events above bar, sorted by time
+func MakeEventMarkers(events history.Events) ([]byte, error) {
+	var data = make([]marker, 0, len(events))
+
+	for _, event := range events {
+		text := strings.TrimSpace(event.Name + " " + history.EventTypes[event.Type])
+		switch event.Type {
+		case history.MARKET_BUY, history.LIMIT_BUY:
+			data = append(data, marker{event.Time.Unix(), "belowBar", "#26a69a", "arrowUp", text})
+		case history.MARKET_SELL, history.LIMIT_SELL:
+			data = append(data, marker{event.Time.Unix(), "aboveBar", "#ef5350", "arrowDown", text})
+		case history.CLOSE_BUY, history.CLOSE_SELL:
+			data = append(data, marker{event.Time.Unix(), "aboveBar", "#787b86", "circle", text})
+		}
+	}
+
+	// lightweight-charts requires markers in time order
+	sort.SliceStable(data, func(i, j int) bool {
+		return data[i].Time < data[j].Time
+	})
+	return json.Marshal(&data)
+}
+
+// MakeHeader creates chart headers
+func (c *Chart) MakeHeader() ([]byte, error) {
+	return []byte(`
+	<head>
+		<meta name="viewport" content="width=device-width"/>
+		<script src="https://unpkg.com/lightweight-charts@4.1.3/dist/lightweight-charts.standalone.production.js"></script>
+	</head>
+	<style>
+		html{font-family: 'Lato',sans-serif;}
+		body{
+			overflow: auto;
+			background: #0c0e15;
+
+			display: flex;
+			flex-direction: column;
+			align-items: center;
+		}
+		.charts {
+			width: ` + c.SetWidth + `;
+			height: ` + c.SetHeight + `;
+			margin: 25px;
+		}
+	 </style>`), nil
+}
+
+// MakeChart template
+func (c *Chart) MakeChart(name string, bars history.Bars, events history.Events) ([]byte, error) {
+	if name == "" {
+		name = "unknown"
+	}
+	if len(bars) == 0 {
+		return nil, errors.New("no price data")
+	}
+
+	candles, err := MakeCandles(bars)
+	if err != nil {
+		return nil, err
+	}
+	markers, err := MakeEventMarkers(events)
+	if err != nil {
+		return nil, err
+	}
+	js := history.JSString(name)
+
+	s := `
+	<div class="charts" id="` + html.EscapeString(name) + `"></div>
+	<script>
+	(function() {
+		const el = document.getElementById(` + js + `);
+		const chart = LightweightCharts.createChart(el, {
+			autoSize: true,
+			layout: { background: { color: '#131722' }, textColor: '#d1d4dc' },
+			grid: { vertLines: { color: '#2a2e39' }, horzLines: { color: '#2a2e39' } },
+			timeScale: { timeVisible: true },
+			watermark: { visible: true, text: ` + js + `, color: 'rgba(209,212,220,0.3)', fontSize: 14, horzAlign: 'left', vertAlign: 'top' },
+		});
+
+		const price = chart.addCandlestickSeries({
+			upColor: '#26a69a', downColor: '#ef5350',
+			borderVisible: false,
+			wickUpColor: '#26a69a', wickDownColor: '#ef5350',
+		});
+		price.setData(` + string(candles) + `);
+		price.setMarkers(` + string(markers) + `);`
+
+	if c.Volume {
+		vol, err := MakeVolume(bars)
+		if err != nil {
+			return nil, err
+		}
+		s += `
+
+		const volume = chart.addHistogramSeries({
+			priceFormat: { type: 'volume' },
+			priceScaleId: '',
+		});
+		volume.priceScale().applyOptions({ scaleMargins: { top: 0.8, bottom: 0 } });
+		volume.setData(` + string(vol) + `);`
+	}
+
+	return []byte(s + `
+
+		chart.timeScale().fitContent();
+	})();
+	</script>`), nil
+}
+
+// BuildCharts of all bars, with events of the same symbol if any
+func (c *Chart) BuildCharts(m map[string]history.Bars, events map[string]history.Events) (buf []byte, err error) {
+	if len(m) == 0 {
+		return []byte(`no charts history`), errors.New("no charts history")
+	}
+
+	buf, err = c.MakeHeader()
+	if err != nil {
+		return nil, err
+	}
+
+	symbols := make([]string, 0, len(m))
+	for symbol := range m {
+		// only charts with events if we got any
+		if _, ok := events[symbol]; len(events) > 0 && !ok {
+			continue
+		}
+		symbols = append(symbols, symbol)
+	}
+	sort.Strings(symbols)
+
+	for _, symbol := range symbols {
+		chart, err := c.MakeChart(symbol, m[symbol], events[symbol])
+		if err != nil {
+			log.Println(err)
+			continue
+		}
+		buf = append(buf, chart...)
+	}
+
+	return buf, nil
+}
@@ -0,0 +1,57 @@
+package tradingview
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/slicken/history"
+)
+
+func TestBuildCharts(t *testing.T) {
+	start := time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)
+	bars := make(history.Bars, 20)
+	for i := 0; i < 20; i++ {
+		c := 100 + float64(i)
+		bars[19-i] = history.Bar{Time: start.Add(time.Duration(i) * time.Hour), Open: c, High: c + 1, Low: c - 1, Close: c, Volume: 1}
+	}
+	events := history.Events{
+		history.BuyBracket("BTCUSDT1h", bars[5].Time, 1, bars[5].Close, 0, 0),
+		history.BuyBracket("BTCUSDT1h", bars[15].Time, 1, bars[15].Close, 0, 0),
+	}
+
+	c := DefaultChart()
+	c.Volume = true
+	buf, err := c.BuildCharts(map[string]history.Bars{"BTCUSDT1h": bars, "ETHUSDT1h": bars}, map[string]history.Events{"BTCUSDT1h": events})
+	if err != nil {
+		t.Fatal(err)
+	}
+	out := string(buf)
+
+	for _, s := range []string{"lightweight-charts", `id="BTCUSDT1h"`, "addCandlestickSeries", "addHistogramSeries", `"arrowUp"`} {
+		if !strings.Contains(out, s) {
+			t.Errorf("chart does not contain %q", s)
+		}
+	}
+	// candles are oldest first with time in unix seconds
+	var candles []string
+	for i := len(bars) - 1; i >= 0; i-- {
+		b := bars[i]
+		candles = append(candles, fmt.Sprintf(`{"time":%d,"open":%v,"high":%v,"low":%v,"close":%v}`, b.Time.Unix(), b.Open, b.High, b.Low, b.Close))
+	}
+	if !strings.Contains(out, "["+strings.Join(candles, ",")+"]") {
+		t.Error("chart does not contain the candles of bars")
+	}
+	// only symbols with events are charted
+	if strings.Contains(out, "ETHUSDT1h") {
+		t.Error("chart of symbol without events")
+	}
+	// markers are in time order
+	first := strings.Index(out, `"time":`+strconv.FormatInt(bars[15].Time.Unix(), 10)+`,"position"`)
+	second := strings.Index(out, `"time":`+strconv.FormatInt(bars[5].Time.Unix(), 10)+`,"position"`)
+	if first < 0 || second < first {
+		t.Errorf("markers not in time order")
+	}
+}
@@ -2,38 +2,131 @@ package history
 
 import (
 	"encoding/json"
+	"fmt"
 	"log"
 	"os"
 	"path/filepath"
+	"sort"
 	"strings"
+	"sync"
 	"time"
 )
 
 var (
-	maxlimit = 1000
-	datadir  = "data"
+	maxlimit   = 1000
+	datadir    = "data"
+	maxtries   = 3
+	minbackoff = time.Second
+	// csize is the buffer of History.C
+	csize = 256
+
+	// dirMu guards datadir
+	dirMu sync.RWMutex
+	// fileLocks holds a *sync.Mutex by file path, so writes to the same file are serialized
+	fileLocks sync.Map
 )
 
-// Setmaxlimit limits new data request
+// Setmaxlimit limits new data request of this history, 0 uses the default
 func (h *History) SetMaxLimit(v int) {
-	maxlimit = v
+	h.Lock()
+	h.maxlimit = v
+	h.Unlock()
+}
+
+// maxLimit returns the download limit, call with lock held
+func (h *History) maxLimit() int {
+	if h.maxlimit > 0 {
+		return h.maxlimit
+	}
+	return maxlimit
 }
 
-// Setdatadir to store files in
+// Setdatadir to store files in, safe to call while history is updating.
+// datadir is shared by all historys
 func (h *History) SetDataDir(v string) {
+	dirMu.Lock()
 	datadir = v
+	dirMu.Unlock()
+
+	// cached bars are from the old dir
+	h.Lock()
+	if h.cache != nil {
+		h.cache = newBarCache(h.cache.size)
+	}
+	h.Unlock()
+}
+
+// SetRetry sets how many times a download is tried and the base backoff
+// duration, witch doubles on every failed try
+func (h *History) SetRetry(maxTries int, base time.Duration) {
+	h.Lock()
+	h.maxTries = maxTries
+	h.backoff = base
+	h.Unlock()
+}
+
+// SetPersist enables or disables saving new bars to files, enabled by default
+func (h *History) SetPersist(v bool) {
+	h.Lock()
+	h.nopersist = !v
+	h.Unlock()
+}
+
+// SetBalance sets the starting balance of PortfolioTest, 0 uses the default
+func (h *History) SetBalance(v float64) {
+	h.Lock()
+	h.balance = v
+	h.Unlock()
+}
+
+// dataDir returns datadir safely
+func dataDir() string {
+	dirMu.RLock()
+	defer dirMu.RUnlock()
+	return datadir
+}
+
+// EventsDir returns the directory for events files in datadir, apart from bars
+// so StoredSymbols does not list them
+func EventsDir() string {
+	return filepath.Join(dataDir(), "events")
+}
+
+// barsFile returns file path of symbol in datadir
+func barsFile(symbol string) string {
+	return filepath.Join(dataDir(), strings.ToLower(symbol)+".json")
+}
+
+// fileLock returns the write lock of file path
+func fileLock(path string) *sync.Mutex {
+	mu, _ := fileLocks.LoadOrStore(path, new(sync.Mutex))
+	return mu.(*sync.Mutex)
+}
+
+// symbols returns the keys of m in sorted order
+func symbols(m map[string]Bars) []string {
+	keys := make([]string, 0, len(m))
+	for symbol := range m {
+		keys = append(keys, symbol)
+	}
+	sort.Strings(keys)
+	return keys
 }
 
 // StoredSymbols
 func StoredSymbols() ([]string, error) {
-	files, err := os.ReadDir(datadir)
+	files, err := os.ReadDir(dataDir())
 	if err != nil {
 		return nil, err
 	}
 
 	var symbols []string
 	for _, f := range files {
-		symbols = append(symbols, f.Name()[:len(f.Name())-5])
+		// only bars files, not the events dir or other files
+		if f.IsDir() || !strings.HasSuffix(f.Name(), ".json") {
+			continue
+		}
+		symbols = append(symbols, strings.TrimSuffix(f.Name(), ".json"))
 	}
 	return symbols, nil
 }
@@ -41,10 +134,8 @@ func StoredSymbols() ([]string, error) {
 // ReadBars loads ars from file
 func ReadBars(symbol string) (Bars, error) {
 	var bars Bars
-	filename := strings.ToLower(symbol) + ".json"
-	filepath := filepath.Join(datadir, filename)
 
-	b, err := os.ReadFile(filepath)
+	b, err := os.ReadFile(barsFile(symbol))
 	if err != nil {
 		return bars, err
 	}
@@ -56,8 +147,12 @@ func ReadBars(symbol string) (Bars, error) {
 	return bars, nil
 }
 
-// WriteBars saves bars to file
+// WriteBars saves bars to file, merged with bars already in it
 func WriteBars(symbol string, bars Bars) error {
+	mu := fileLock(barsFile(symbol))
+	mu.Lock()
+	defer mu.Unlock()
+
 	// merge if file alredy exist
 	if old, err := ReadBars(symbol); err == nil {
 		// skip if new last equeals old of
@@ -67,31 +162,225 @@ func WriteBars(symbol string, bars Bars) error {
 		bars = merge(old, bars)
 	}
 
+	return writeBars(symbol, bars)
+}
+
+// writeBars overwrites file of symbol with bars, call with its file lock held
+func writeBars(symbol string, bars Bars) error {
 	b, err := json.MarshalIndent(&bars, "", "\t")
 	if err != nil {
 		return err
 	}
 
 	// create datadir if does not exist
-	if _, err := os.Stat(datadir); err != nil {
-		if err := os.MkdirAll(datadir, os.ModePerm); err != nil {
+	dir := dataDir()
+	if _, err := os.Stat(dir); err != nil {
+		if err := os.MkdirAll(dir, os.ModePerm); err != nil {
 			log.Fatal(err)
 		}
 	}
 
-	filename := strings.ToLower(symbol) + ".json"
-	filepath := filepath.Join(datadir, filename)
+	return os.WriteFile(filepath.Join(dir, strings.ToLower(symbol)+".json"), b, 0644)
+}
 
-	return os.WriteFile(filepath, b, 0644)
+// WriteJSON saves events to file, writes to the same file are serialized.
+// the dir of file is created if it does not exist
+func (events Events) WriteJSON(filename string) error {
+	b, err := json.MarshalIndent(&events, "", "\t")
+	if err != nil {
+		return err
+	}
+
+	// create dir if does not exist
+	dir := filepath.Dir(filename)
+	if _, err := os.Stat(dir); err != nil {
+		if err := os.MkdirAll(dir, os.ModePerm); err != nil {
+			return err
+		}
+	}
+
+	mu := fileLock(filename)
+	mu.Lock()
+	defer mu.Unlock()
+	return os.WriteFile(filename, b, 0644)
 }
 
-// calculates how many bars between time.now and time.last
-func calcLimit(last time.Time, period time.Duration) int {
-	t := time.Until(last)
+// WriteJSONTo saves events to file in dir, see EventsDir
+func (events Events) WriteJSONTo(dir, filename string) error {
+	return events.WriteJSON(filepath.Join(dir, filename))
+}
+
+// ReadEventsJSON loads events from file
+func ReadEventsJSON(filename string) (Events, error) {
+	var events Events
+
+	b, err := os.ReadFile(filename)
+	if err != nil {
+		return events, err
+	}
+
+	if err = json.Unmarshal(b, &events); err != nil {
+		return events, err
+	}
+
+	return events, nil
+}
+
+// WriteJSON saves bars to file
+func (bars Bars) WriteJSON(filename string) error {
+	b, err := json.MarshalIndent(&bars, "", "\t")
+	if err != nil {
+		return err
+	}
+
+	return os.WriteFile(filename, b, 0644)
+}
+
+// ReadJSON loads bars from file
+func ReadJSON(filename string) (Bars, error) {
+	var bars Bars
+
+	b, err := os.ReadFile(filename)
+	if err != nil {
+		return bars, err
+	}
+
+	if err = json.Unmarshal(b, &bars); err != nil {
+		return bars, err
+	}
+
+	return bars.Sort(), nil
+}
+
+// ExportAll writes bars of every stored and loaded symbol to its own file in dir,
+// format is "json" or "csv". returns number of files written
+func (h *History) ExportAll(dir string, format string) (int, error) {
+	format = strings.ToLower(format)
+	if format != "json" && format != "csv" {
+		return 0, fmt.Errorf("unknown format %q", format)
+	}
+	if err := os.MkdirAll(dir, os.ModePerm); err != nil {
+		return 0, err
+	}
+
+	// loaded bars are newer then stored
+	all := make(map[string]Bars)
+	stored, _ := StoredSymbols()
+	for _, symbol := range stored {
+		if bars, err := ReadBars(symbol); err == nil {
+			all[strings.ToLower(symbol)] = bars
+		}
+	}
+	for symbol, bars := range h.Map() {
+		all[strings.ToLower(symbol)] = merge(all[strings.ToLower(symbol)], bars)
+	}
+
+	var n int
+	for _, symbol := range symbols(all) {
+		filename := filepath.Join(dir, symbol+"."+format)
+
+		var err error
+		if format == "csv" {
+			err = all[symbol].WriteCSV(filename)
+		} else {
+			err = all[symbol].WriteJSON(filename)
+		}
+		if err != nil {
+			return n, err
+		}
+		n++
+	}
+
+	return n, nil
+}
+
+// ImportDir adds bars of every .json and .csv file in dir to history and saves them
+// to datadir unless persist is off. the symbol is the filename without extension.
+// other files are skipped
+func (h *History) ImportDir(dir string) error {
+	files, err := os.ReadDir(dir)
+	if err != nil {
+		return err
+	}
+
+	h.RLock()
+	persist := !h.nopersist
+	h.RUnlock()
+
+	var failed []string
+	for _, f := range files {
+		if f.IsDir() {
+			continue
+		}
+		ext := filepath.Ext(f.Name())
+		symbol := strings.TrimSuffix(f.Name(), ext)
+		filename := filepath.Join(dir, f.Name())
+
+		var bars Bars
+		switch strings.ToLower(ext) {
+		case ".json":
+			bars, err = ReadJSON(filename)
+		case ".csv":
+			bars, err = ReadCSV(filename)
+		default:
+			continue
+		}
+		if err == nil {
+			err = h.importBars(symbol, bars, persist)
+		}
+		if err != nil {
+			log.Printf("could not import %s: %v\n", f.Name(), err)
+			failed = append(failed, fmt.Sprintf("%s: %v", f.Name(), err))
+		}
+	}
+
+	if len(failed) > 0 {
+		return fmt.Errorf("%d files failed: %s", len(failed), strings.Join(failed, "; "))
+	}
+	return nil
+}
+
+// importBars adds bars to history. Add saves bars of loaded symbols, but not of
+// symbols it loads, so those are saved here once
+func (h *History) importBars(symbol string, bars Bars, persist bool) error {
+	h.RLock()
+	_, loaded := h.bars[symbol]
+	h.RUnlock()
+
+	if err := h.Add(symbol, bars); err != nil {
+		if err == errNoNewBars {
+			return nil
+		}
+		return err
+	}
+	if loaded || !persist {
+		return nil
+	}
+
+	err := WriteBars(symbol, bars)
+	h.Lock()
+	h.invalidate(symbol)
+	h.Unlock()
+	return err
+}
+
+// JSString quotes s as a js string literal, safe inside html and <script> tags
+// of charts
+func JSString(s string) string {
+	// json.Marshal escapes <, > and & so '</script>' can not end the tag
+	b, _ := json.Marshal(s)
+	return string(b)
+}
+
+// calculates how many bars between now and time.last
+func calcLimit(now, last time.Time, period time.Duration) int {
+	t := last.Sub(now)
 	return -int(t / period)
 }
 
-// Splits Sumbol to Pair and Timeframe
+// Splits Sumbol to Pair and Timeframe, the longest valid timeframe suffix wins.
+// this is ambiguous when the pair ends with a digit, "ABC115m" splits to "ABC1" and "15m"
+// and not "ABC11" and "5m". use SplitSymbolQuote when the quote asset is known
 func SplitSymbol(s string) (pair string, tf string) {
 	// split pair and timeframe
 	for i := len(s); i >= 0; i-- {
@@ -106,6 +395,18 @@ func SplitSymbol(s string) (pair string, tf string) {
 	return pair, tf
 }
 
+// SplitSymbolQuote splits Symbol to Pair and Timeframe at the last occurrence of quote,
+// "1000SATSUSDT1h" with quote "USDT" splits to "1000SATSUSDT" and "1h".
+// falls back to SplitSymbol if quote is not followed by a valid timeframe
+func SplitSymbolQuote(s, quote string) (pair string, tf string) {
+	i := strings.LastIndex(strings.ToUpper(s), strings.ToUpper(quote))
+	if quote == "" || i < 0 || TFInterval(s[i+len(quote):]) == 0 {
+		return SplitSymbol(s)
+	}
+
+	return s[:i+len(quote)], s[i+len(quote):]
+}
+
 // ToUnixTime converts time to Unix time
 func ToUnixTime(t time.Time) int64 {
 	return t.Unix() / 1e6
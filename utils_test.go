@@ -0,0 +1,121 @@
+package history
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+func TestImportDir(t *testing.T) {
+	h := newTestHistory(t)
+	src := t.TempDir()
+	if err := makeBars(10, time.Hour).WriteJSON(filepath.Join(src, "BTCUSDT1h.json")); err != nil {
+		t.Fatal(err)
+	}
+	// bar without Time must fail, not panic
+	if err := os.WriteFile(filepath.Join(src, "ETHUSDT1h.json"), []byte(`[{"Open":1,"High":1,"Low":1,"Close":1,"Volume":1}]`), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	if err := h.ImportDir(src); err == nil {
+		t.Fatal("want error for bar without Time")
+	}
+	if got := len(h.Bars("BTCUSDT1h")); got != 10 {
+		t.Fatalf("imported %d bars, want 10", got)
+	}
+	stored, err := ReadBars("BTCUSDT1h")
+	if err != nil || len(stored) != 10 {
+		t.Fatalf("stored %d bars (%v), want 10", len(stored), err)
+	}
+}
+
+func TestImportDirTwice(t *testing.T) {
+	h := newTestHistory(t)
+	src := t.TempDir()
+	if err := makeBars(10, time.Hour).WriteJSON(filepath.Join(src, "BTCUSDT1h.json")); err != nil {
+		t.Fatal(err)
+	}
+
+	for i := 0; i < 2; i++ {
+		if err := h.ImportDir(src); err != nil {
+			t.Fatalf("import %d: %v", i, err)
+		}
+	}
+	stored, err := ReadBars("BTCUSDT1h")
+	if err != nil || len(stored) != 10 {
+		t.Fatalf("stored %d bars (%v), want 10", len(stored), err)
+	}
+}
+
+func TestEventsAreNotStoredSymbols(t *testing.T) {
+	h := newTestHistory(t)
+	if err := WriteBars("BTCUSDT1h", makeBars(10, time.Hour)); err != nil {
+		t.Fatal(err)
+	}
+
+	events := Events{BuyBracket("BTCUSDT1h", start, 1, 100, 0, 0)}
+	if err := events.WriteJSONTo(EventsDir(), "events.json"); err != nil {
+		t.Fatal(err)
+	}
+	if got, err := ReadEventsJSON(filepath.Join(EventsDir(), "events.json")); err != nil || len(got) != 1 {
+		t.Fatalf("read %d events (%v), want 1", len(got), err)
+	}
+
+	stored, err := StoredSymbols()
+	if err != nil || len(stored) != 1 || stored[0] != "btcusdt1h" {
+		t.Fatalf("stored symbols %v (%v), want [btcusdt1h]", stored, err)
+	}
+	n, err := h.ExportAll(t.TempDir(), "csv")
+	if err != nil || n != 1 {
+		t.Fatalf("exported %d symbols (%v), want 1", n, err)
+	}
+}
+
+func TestReadBarsReturnsUTC(t *testing.T) {
+	newTestHistory(t)
+	bars := makeBars(5, time.Hour)
+	local := time.FixedZone("UTC+2", 2*60*60)
+	for i := range bars {
+		bars[i].Time = bars[i].Time.In(local)
+	}
+	if err := WriteBars("BTCUSDT1h", bars); err != nil {
+		t.Fatal(err)
+	}
+
+	got, err := ReadBars("BTCUSDT1h")
+	if err != nil || len(got) != 5 {
+		t.Fatalf("read %d bars (%v), want 5", len(got), err)
+	}
+	for i, bar := range got {
+		if bar.Time.Location() != time.UTC || !bar.Time.Equal(bars[i].Time) {
+			t.Fatalf("bar %d time %v, want %v in UTC", i, bar.Time, bars[i].Time)
+		}
+	}
+}
+
+func TestSplitSymbol(t *testing.T) {
+	for _, tc := range []struct {
+		symbol, quote, pair, tf string
+	}{
+		{"BTCUSDT1h", "", "BTCUSDT", "1h"},
+		{"ETHBTC15m", "", "ETHBTC", "15m"},
+		// a pair ending with a digit is ambiguous without quote
+		{"ABC115m", "", "ABC1", "15m"},
+		{"1000SATSUSDT1h", "USDT", "1000SATSUSDT", "1h"},
+		{"ABC1USDT15m", "usdt", "ABC1USDT", "15m"},
+		// quote not followed by a timeframe falls back to SplitSymbol
+		{"BTCUSDT1h", "BUSD", "BTCUSDT", "1h"},
+	} {
+		pair, tf := SplitSymbolQuote(tc.symbol, tc.quote)
+		if pair != tc.pair || tf != tc.tf {
+			t.Errorf("SplitSymbolQuote(%q, %q) = %q, %q, want %q, %q", tc.symbol, tc.quote, pair, tf, tc.pair, tc.tf)
+		}
+	}
+}
+
+func TestJSString(t *testing.T) {
+	if got := JSString(`a"</script>`); got != `"a\"\u003c/script\u003e"` {
+		t.Fatalf("got %s", got)
+	}
+}